@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope is a permission granted to a caller and required by a route.
+type scope string
+
+const (
+	scopeMenuRead    scope = "menu:read"
+	scopeMenuWrite   scope = "menu:write"
+	scopeOrdersWrite scope = "orders:write"
+	scopeAdmin       scope = "admin" // grants every other scope
+)
+
+var knownScopes = []scope{scopeMenuRead, scopeMenuWrite, scopeOrdersWrite, scopeAdmin}
+
+// readOnly reports whether s only lets callers read, so routes needing it may
+// stay open while authentication is off.
+func (s scope) readOnly() bool {
+	return s == scopeMenuRead
+}
+
+// apiKeyHashPrefix marks the hash of a key's secret in the configuration.
+const apiKeyHashPrefix = "sha256:"
+
+// apiKey is a configured key. Only the hash of its secret is kept.
+type apiKey struct {
+	id     string
+	hash   [sha256.Size]byte
+	scopes []scope
+}
+
+// parseAPIKey parses a key written as "sha256:<hex> <scope>...".
+func parseAPIKey(id, spec string) (*apiKey, error) {
+	if id == "" || strings.ContainsAny(id, " \t") {
+		return nil, fmt.Errorf("%q is not a valid key ID", id)
+	}
+	fields := strings.Fields(spec)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%s: expected sha256:<hex> followed by at least one scope", id)
+	}
+
+	hexHash, ok := strings.CutPrefix(fields[0], apiKeyHashPrefix)
+	hash, err := hex.DecodeString(hexHash)
+	if !ok || err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("%s: expected the secret's hash as sha256:<64 hex digits>", id)
+	}
+	key := &apiKey{id: id, hash: [sha256.Size]byte(hash)}
+	for _, field := range fields[1:] {
+		if !slices.Contains(knownScopes, scope(field)) {
+			return nil, fmt.Errorf("%s: unknown scope %q", id, field)
+		}
+		key.scopes = append(key.scopes, scope(field))
+	}
+	return key, nil
+}
+
+// parseAPIKeyFile parses one "<id> sha256:<hex> <scope>..." key per line.
+// Blank lines and lines starting with # are skipped.
+func parseAPIKeyFile(r io.Reader) ([]*apiKey, error) {
+	var keys []*apiKey
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, spec, _ := strings.Cut(line, " ")
+		key, err := parseAPIKey(id, spec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// apiKeySet is an AuthConfig loaded for use on every request.
+type apiKeySet struct {
+	byHash    map[[sha256.Size]byte]*apiKey
+	anonymous []scope
+}
+
+// loadAPIKeys reads the keys of cfg, including its keys file.
+func loadAPIKeys(cfg AuthConfig) (*apiKeySet, error) {
+	var keys []*apiKey
+	if cfg.APIKeysFile != "" {
+		data, err := os.ReadFile(cfg.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("api_keys_file: %w", err)
+		}
+		if keys, err = parseAPIKeyFile(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("api_keys_file: %w", err)
+		}
+	}
+	for id, spec := range cfg.APIKeys {
+		key, err := parseAPIKey(id, spec)
+		if err != nil {
+			return nil, fmt.Errorf("api_keys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	set := &apiKeySet{byHash: map[[sha256.Size]byte]*apiKey{}}
+	ids := map[string]bool{}
+	for _, key := range keys {
+		if ids[key.id] {
+			return nil, fmt.Errorf("key ID %q is configured more than once", key.id)
+		}
+		if _, ok := set.byHash[key.hash]; ok {
+			return nil, fmt.Errorf("key %q reuses the secret of another key", key.id)
+		}
+		ids[key.id] = true
+		set.byHash[key.hash] = key
+	}
+	for _, s := range cfg.AnonymousScopes {
+		if !slices.Contains(knownScopes, scope(s)) {
+			return nil, fmt.Errorf("anonymous_scopes: unknown scope %q", s)
+		}
+		set.anonymous = append(set.anonymous, scope(s))
+	}
+	return set, nil
+}
+
+// lookup returns the key whose secret is secret. Keys are found by the hash
+// of the presented secret, so how long a lookup takes says nothing about the
+// stored ones.
+func (s *apiKeySet) lookup(secret string) (*apiKey, bool) {
+	key, ok := s.byHash[sha256.Sum256([]byte(secret))]
+	return key, ok
+}
+
+// apiKeyID returns the ID of the configured key whose secret is secret.
+func (a *authenticator) apiKeyID(secret string) (string, bool) {
+	key, ok := a.keys.Load().lookup(secret)
+	if !ok {
+		return "", false
+	}
+	return key.id, true
+}
+
+// principal is who a request was authenticated as.
+type principal struct {
+	id         string // the key ID or the token's subject; never a secret
+	method     string
+	scopes     []scope
+	restaurant string // restaurant_id claim of a bearer token
+}
+
+// Authentication methods, as recorded on spans.
+const (
+	authMethodAnonymous = "anonymous"
+	authMethodAPIKey    = "api_key"
+	authMethodJWT       = "jwt"
+)
+
+func (p *principal) has(s scope) bool {
+	return slices.Contains(p.scopes, s) || slices.Contains(p.scopes, scopeAdmin)
+}
+
+// mayActFor reports whether p may act for the named restaurant, editing its
+// items or taking its orders. A token tied to one restaurant by its
+// restaurant_id claim may only act for that restaurant unless it also grants
+// admin.
+func (p *principal) mayActFor(restaurant string) bool {
+	return p.restaurant == "" || slices.Contains(p.scopes, scopeAdmin) || restaurantID(restaurant) == p.restaurant
+}
+
+type principalKey struct{}
+
+// principalFrom returns the principal the request was authenticated as, or
+// nil when its route is public or authentication is off.
+func principalFrom(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+// authCredentials are what a caller presented. A bearer token takes precedence
+// over an API key.
+type authCredentials struct {
+	apiKey string
+	bearer string
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header value,
+// or "" for other schemes.
+func bearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Why a request was refused, as counted and logged.
+const (
+	authFailureMissing   = "missing"
+	authFailureInvalid   = "invalid"
+	authFailureForbidden = "forbidden"
+	// authFailureNotConfigured refuses routes needing more than menu:read
+	// while authentication is off.
+	authFailureNotConfigured = "not_configured"
+)
+
+// authenticator checks the API key or bearer token of requests to routes
+// that need a scope.
+type authenticator struct {
+	keys     atomic.Pointer[apiKeySet]
+	bearer   atomic.Pointer[bearerVerifier] // nil without a JWKS
+	failures metric.Int64Counter
+}
+
+func newAuthenticator(cfg AuthConfig) (*authenticator, error) {
+	keys, err := loadAPIKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := meter.Int64Counter("http.server.auth.failures",
+		metric.WithDescription("HTTP requests refused for missing, invalid or insufficient credentials."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("auth failure counter: %w", err)
+	}
+
+	a := &authenticator{failures: failures}
+	a.keys.Store(keys)
+	a.configureBearer(cfg)
+	return a, nil
+}
+
+// configure loads the keys of cfg, keeping the current ones if they are
+// invalid.
+func (a *authenticator) configure(cfg AuthConfig) {
+	keys, err := loadAPIKeys(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid API keys, keeping the previous ones")
+		return
+	}
+	a.keys.Store(keys)
+	log.Info().Int("keys", len(keys.byHash)).Msg("API keys loaded")
+	if err := validateJWKSSource(cfg); err != nil {
+		log.Error().Err(err).Msg("Invalid JWKS settings, keeping the previous ones")
+		return
+	}
+	a.configureBearer(cfg)
+}
+
+// configureBearer accepts bearer tokens signed by the JWKS of cfg, or stops
+// accepting them if it has none. The keys are fetched on first use.
+func (a *authenticator) configureBearer(cfg AuthConfig) {
+	switch current := a.bearer.Load(); {
+	case cfg.JWKSFile == "" && cfg.JWKSURL == "":
+		a.bearer.Store(nil)
+	case current == nil || current.settings != bearerSettingsOf(cfg):
+		a.bearer.Store(newBearerVerifier(cfg))
+	}
+}
+
+// watch reloads the keys whenever the keys file of cfg changes.
+func (a *authenticator) watch(ctx context.Context, interval time.Duration, cfg func() AuthConfig) {
+	watchFiles(ctx, interval, []string{cfg().APIKeysFile}, func() { a.configure(cfg()) })
+}
+
+// enabled reports whether any key or a JWKS is configured; until one is,
+// every route is public.
+func (a *authenticator) enabled() bool {
+	return len(a.keys.Load().byHash) > 0 || a.bearer.Load() != nil
+}
+
+// authorize returns the principal a caller presenting creds acts as, or why
+// it may not use required. Why a bearer token was rejected is recorded on the
+// span of ctx.
+func (a *authenticator) authorize(ctx context.Context, creds authCredentials, required scope) (*principal, string) {
+	keys := a.keys.Load()
+	p := &principal{method: authMethodAnonymous, scopes: keys.anonymous}
+	switch {
+	case creds.bearer != "":
+		verifier := a.bearer.Load()
+		if verifier == nil {
+			return nil, authFailureInvalid
+		}
+		var err error
+		if p, err = verifier.verify(ctx, creds.bearer); err != nil {
+			trace.SpanFromContext(ctx).RecordError(err)
+			return nil, authFailureInvalid
+		}
+	case creds.apiKey != "":
+		key, ok := keys.lookup(creds.apiKey)
+		if !ok {
+			return nil, authFailureInvalid
+		}
+		p = &principal{id: key.id, method: authMethodAPIKey, scopes: key.scopes}
+	}
+
+	switch {
+	case p.has(required):
+		return p, ""
+	case p.method == authMethodAnonymous:
+		return nil, authFailureMissing
+	}
+	return p, authFailureForbidden
+}
+
+// record puts the outcome of authorize on the span and the log line, and
+// counts refusals. The key ID or token subject is recorded, never the secret
+// or the token.
+func (a *authenticator) record(ctx context.Context, p *principal, failure string, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if p != nil {
+		span.SetAttributes(attribute.String("auth.method", p.method))
+		switch {
+		case p.method == authMethodJWT:
+			span.SetAttributes(attribute.String("enduser.id", p.id))
+			addLogField(ctx, "subject", p.id)
+			if p.restaurant != "" {
+				span.SetAttributes(attribute.String("auth.restaurant_id", p.restaurant))
+				addLogField(ctx, "restaurant_id", p.restaurant)
+			}
+		case p.id != "":
+			span.SetAttributes(attribute.String("auth.api_key.id", p.id))
+			addLogField(ctx, "api_key_id", p.id)
+		}
+	}
+	if failure == "" {
+		return
+	}
+	a.failures.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("reason", failure))...))
+	span.SetAttributes(attribute.String("auth.failure", failure))
+	addLogField(ctx, "auth_failure", failure)
+}
+
+// challenge sets WWW-Authenticate to the methods a refused caller may use.
+// invalid marks a rejected bearer token as such.
+func (a *authenticator) challenge(w http.ResponseWriter, creds authCredentials, invalid bool) {
+	if len(a.keys.Load().byHash) > 0 {
+		w.Header().Add("WWW-Authenticate", `ApiKey header="`+apiKeyHeader+`"`)
+	}
+	if a.bearer.Load() != nil {
+		if invalid && creds.bearer != "" {
+			w.Header().Add("WWW-Authenticate", `Bearer error="invalid_token"`)
+		} else {
+			w.Header().Add("WWW-Authenticate", "Bearer")
+		}
+	}
+}
+
+// require wraps the handler of rt in a check for the scope its operation
+// needs, read from an Authorization bearer token or the X-API-Key header.
+// Routes without a scope stay public. Until authentication is configured,
+// routes that need more than menu:read are refused rather than opened to
+// everyone.
+func (a *authenticator) require(rt route, next http.Handler) http.Handler {
+	required := rt.operation.Scope
+	if required == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !a.enabled() && !required.readOnly():
+			a.record(r.Context(), nil, authFailureNotConfigured,
+				attribute.String("http.route", rt.path()),
+				attribute.String("http.request.method", rt.method()),
+			)
+			writeError(w, r, http.StatusServiceUnavailable, errCodeAuthNotConfigured,
+				fmt.Sprintf("Routes needing %s are disabled until API keys or a JWKS are configured", required))
+			return
+		case !a.enabled():
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		creds := authCredentials{apiKey: r.Header.Get(apiKeyHeader), bearer: bearerToken(r.Header.Get("Authorization"))}
+		p, failure := a.authorize(r.Context(), creds, required)
+		a.record(r.Context(), p, failure,
+			attribute.String("http.route", rt.path()),
+			attribute.String("http.request.method", rt.method()),
+		)
+		switch failure {
+		case "":
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+		case authFailureForbidden:
+			writeError(w, r, http.StatusForbidden, errCodeForbidden,
+				fmt.Sprintf("Credentials lack the %s scope", required))
+		case authFailureMissing:
+			a.challenge(w, creds, false)
+			writeError(w, r, http.StatusUnauthorized, errCodeUnauthenticated, "An API key in "+apiKeyHeader+" or a bearer token is required")
+		default:
+			a.challenge(w, creds, true)
+			writeError(w, r, http.StatusUnauthorized, errCodeUnauthenticated, "Invalid API key or bearer token")
+		}
+	})
+}
+
+// newAPIKey returns a random secret for key id and the line that configures
+// it in an API keys file.
+func newAPIKey(id string, scopes []string) (secret, line string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret = "mk_" + base64.RawURLEncoding.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(secret))
+	line = id + " " + apiKeyHashPrefix + hex.EncodeToString(sum[:]) + " " + strings.Join(scopes, " ")
+	if _, err := parseAPIKey(id, strings.TrimPrefix(line, id+" ")); err != nil {
+		return "", "", err
+	}
+	return secret, line, nil
+}
+
+// runAPIKeyCommand implements `mock-service apikey <id> <scope>...`, which
+// prints a new secret to hand to the client and the hashed line to add to
+// the API keys file.
+func runAPIKeyCommand(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: mock-service apikey <id> <scope>...")
+	}
+	secret, line, err := newAPIKey(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "secret: %s\nkeys file line: %s\n", secret, line)
+	return err
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	menuv1 "github.com/blackswan/mock-go/proto/menu/v1"
+)
+
+// hashedAPIKey configures secret with scopes the way AuthConfig.APIKeys
+// expects.
+func hashedAPIKey(secret string, scopes ...string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:]) + " " + strings.Join(scopes, " ")
+}
+
+func newAuthenticatedServer(t *testing.T, cfg AuthConfig) *Server {
+	t.Helper()
+
+	server := newTestServerWithoutFaults()
+	server.rateLimiter.configure(RateLimitConfig{})
+	keys, err := loadAPIKeys(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.auth.keys.Store(keys)
+	return server
+}
+
+func TestParseAPIKey(t *testing.T) {
+	key, err := parseAPIKey("ci", hashedAPIKey("s3cret", "menu:read", "admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.id != "ci" || key.hash != sha256.Sum256([]byte("s3cret")) || len(key.scopes) != 2 {
+		t.Errorf("unexpected key %+v", key)
+	}
+
+	for _, spec := range []string{
+		"",
+		hashedAPIKey("s3cret"),
+		"s3cret menu:read",
+		"sha256:abcd menu:read",
+		hashedAPIKey("s3cret", "menu:delete"),
+	} {
+		if _, err := parseAPIKey("ci", spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys")
+	file := "# tablets\n\ntablet-1 " + hashedAPIKey("tablet", "orders:write") + "\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadAPIKeys(AuthConfig{
+		APIKeysFile:     path,
+		APIKeys:         map[string]string{"ci": hashedAPIKey("ci", "menu:read")},
+		AnonymousScopes: []string{"menu:read"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := keys.lookup("tablet"); !ok || key.id != "tablet-1" {
+		t.Errorf("expected the file's key to be found by its secret, got %+v", key)
+	}
+	if _, ok := keys.lookup("ci"); !ok {
+		t.Error("expected the inline key to be found by its secret")
+	}
+	if _, ok := keys.lookup(hashedAPIKey("ci", "menu:read")); ok {
+		t.Error("expected the stored hash not to work as a key")
+	}
+
+	for name, cfg := range map[string]AuthConfig{
+		"duplicate ID": {APIKeysFile: path, APIKeys: map[string]string{"tablet-1": hashedAPIKey("other", "menu:read")}},
+		"shared secret": {APIKeys: map[string]string{
+			"a": hashedAPIKey("same", "menu:read"),
+			"b": hashedAPIKey("same", "admin"),
+		}},
+		"missing file":    {APIKeysFile: filepath.Join(t.TempDir(), "missing")},
+		"unknown scope":   {AnonymousScopes: []string{"everything"}},
+		"malformed entry": {APIKeys: map[string]string{"ci": "hunter2"}},
+	} {
+		if _, err := loadAPIKeys(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAuth_PublicUntilKeysAreConfigured(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+	if rec := serveConditional(handler, "/api/menu", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected the menu to be public without keys, got %d", rec.Code)
+	}
+}
+
+func TestAuth_WritesRefusedUntilKeysAreConfigured(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	body := `{"id":"1","name":"Special","price":9.5,"available":true,"description":"","restaurant":"Tony's Pizza","category":"Pizza","prep_time_minutes":5}`
+	req := httptest.NewRequest(http.MethodPut, "/api/menu/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", problemContentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d: %s", http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	}
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Code != errCodeAuthNotConfigured {
+		t.Errorf("expected an AUTH_NOT_CONFIGURED problem, got %q", rec.Body.String())
+	}
+	if rec := serveConditional(handler, "/api/menu/1", nil); strings.Contains(rec.Body.String(), "Special") {
+		t.Errorf("expected the refused write to leave the item alone, got %s", rec.Body.String())
+	}
+}
+
+func TestAuth_TabletsRefusedUntilKeysAreConfigured(t *testing.T) {
+	ts := httptest.NewServer(newHTTPHandler(NewServer()))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/tablets?restaurant=Thai+Palace"
+	_, resp, err := websocket.Dial(ctx, u, nil)
+	if err == nil {
+		t.Fatal("expected the tablet socket to be refused without authentication configured")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %+v", http.StatusServiceUnavailable, resp)
+	}
+}
+
+func TestAuth_EnforcesRouteScopes(t *testing.T) {
+	server := newAuthenticatedServer(t, AuthConfig{APIKeys: map[string]string{
+		"reader": hashedAPIKey("reader-secret", "menu:read"),
+		"tablet": hashedAPIKey("tablet-secret", "orders:write"),
+		"ops":    hashedAPIKey("ops-secret", "admin"),
+	}})
+	handler := newHTTPHandler(server)
+
+	testCases := []struct {
+		name, url, key string
+		want           int
+		code           errorCode
+	}{
+		{"no key", "/api/menu", "", http.StatusUnauthorized, errCodeUnauthenticated},
+		{"unknown key", "/api/menu", "guess", http.StatusUnauthorized, errCodeUnauthenticated},
+		{"missing scope", "/api/menu", "tablet-secret", http.StatusForbidden, errCodeForbidden},
+		{"scope granted", "/api/menu", "reader-secret", http.StatusOK, ""},
+		{"admin", "/api/v2/menu/1", "ops-secret", http.StatusOK, ""},
+		{"graphql", "/graphql?query={menuItems{id}}", "tablet-secret", http.StatusForbidden, errCodeForbidden},
+		{"health is public", "/health", "", http.StatusOK, ""},
+		{"docs are public", "/openapi.json", "", http.StatusOK, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{"Accept": {problemContentType}}
+			if tc.key != "" {
+				header.Set(apiKeyHeader, tc.key)
+			}
+			rec := serveConditional(handler, tc.url, header)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			if tc.code == "" {
+				return
+			}
+			var body Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != tc.code {
+				t.Errorf("expected a %s problem, got %q", tc.code, rec.Body.String())
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); (tc.want == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("unexpected WWW-Authenticate %q on a %d", challenge, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuth_AnonymousScopes(t *testing.T) {
+	server := newAuthenticatedServer(t, AuthConfig{
+		APIKeys:         map[string]string{"tablet": hashedAPIKey("tablet-secret", "orders:write")},
+		AnonymousScopes: []string{"menu:read"},
+	})
+	handler := newHTTPHandler(server)
+
+	if rec := serveConditional(handler, "/api/menu", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected anonymous menu reads, got %d", rec.Code)
+	}
+	if rec := serveConditional(handler, "/ws/tablets?restaurant=Thai+Palace", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected tablets to need a key, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.7:5000", http.Header{apiKeyHeader: {"guess"}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an invalid key to be refused rather than treated as anonymous, got %d", rec.Code)
+	}
+}
+
+func TestAuth_RecordsKeyIDWithoutSecret(t *testing.T) {
+	recorder := installSpanRecorder()
+	server := newAuthenticatedServer(t, AuthConfig{APIKeys: map[string]string{
+		"reader": hashedAPIKey("reader-secret", "menu:read"),
+	}})
+	seen := len(recorder.Ended())
+
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs)
+
+	handler := loggingMiddleware(newHTTPHandler(server))
+	serveFrom(handler, "/api/menu", "203.0.113.7:5000", http.Header{apiKeyHeader: {"reader-secret"}})
+	serveFrom(handler, "/api/menu", "203.0.113.7:5000", http.Header{apiKeyHeader: {"wrong-secret"}})
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	var ok, refused map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-2]), &ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &refused); err != nil {
+		t.Fatal(err)
+	}
+	if ok["api_key_id"] != "reader" {
+		t.Errorf("expected the key ID to be logged, got %v", ok)
+	}
+	if refused["auth_failure"] != authFailureInvalid {
+		t.Errorf("expected the refusal to be logged, got %v", refused)
+	}
+	if strings.Contains(logs.String(), "secret") {
+		t.Errorf("expected no secret in the logs, got %s", logs.String())
+	}
+
+	var keyIDs []string
+	for _, span := range recorder.Ended()[seen:] {
+		if span.SpanKind() != trace.SpanKindServer {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if strings.Contains(attr.Value.Emit(), "secret") {
+				t.Errorf("expected no secret on the span, got %s=%s", attr.Key, attr.Value.Emit())
+			}
+			if attr.Key == "auth.api_key.id" {
+				keyIDs = append(keyIDs, attr.Value.AsString())
+			}
+		}
+	}
+	if len(keyIDs) != 1 || keyIDs[0] != "reader" {
+		t.Errorf("expected one span with auth.api_key.id=reader, got %v", keyIDs)
+	}
+}
+
+func TestAuth_GRPC(t *testing.T) {
+	server := newAuthenticatedServer(t, AuthConfig{APIKeys: map[string]string{
+		"reader": hashedAPIKey("reader-secret", "menu:read"),
+		"tablet": hashedAPIKey("tablet-secret", "orders:write"),
+	}})
+	_, conn := dialGRPC(t, server)
+	client := menuv1.NewMenuServiceClient(conn)
+
+	for key, want := range map[string]codes.Code{
+		"":              codes.Unauthenticated,
+		"guess":         codes.Unauthenticated,
+		"tablet-secret": codes.PermissionDenied,
+		"reader-secret": codes.OK,
+	} {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, grpcAPIKeyMetadata, key)
+		}
+		_, err := client.ListMenuItems(ctx, &menuv1.ListMenuItemsRequest{})
+		if got := status.Code(err); got != want {
+			t.Errorf("key %q: expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestRunAPIKeyCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := runAPIKeyCommand(&out, []string{"tablet-7", "orders:write"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var secret, line string
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if v, ok := strings.CutPrefix(l, "secret: "); ok {
+			secret = v
+		}
+		if v, ok := strings.CutPrefix(l, "keys file line: "); ok {
+			line = v
+		}
+	}
+	if strings.Contains(line, secret) {
+		t.Fatal("expected the keys file line not to contain the secret")
+	}
+	keys, err := parseAPIKeyFile(strings.NewReader(line))
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("expected the printed line to parse, got %v", err)
+	}
+	if keys[0].hash != sha256.Sum256([]byte(secret)) || keys[0].id != "tablet-7" {
+		t.Errorf("expected the line to configure the printed secret, got %+v", keys[0])
+	}
+
+	if err := runAPIKeyCommand(&out, []string{"tablet-7", "orders:delete"}); err == nil {
+		t.Error("expected an unknown scope to be rejected")
+	}
+}
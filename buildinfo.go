@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=abc123 -X main.buildTime=2025-11-22T10:30:45Z"
+//
+// Anything left empty falls back to what the Go toolchain recorded.
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+var currentBuildInfo = sync.OnceValue(func() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+})
+
+// registerBuildInfoMetric publishes a constant build_info gauge so dashboards
+// can join any series against the version a pod is running.
+func registerBuildInfoMetric(meter metric.Meter) error {
+	info := currentBuildInfo()
+	attrs := metric.WithAttributes(
+		attribute.String("version", info.Version),
+		attribute.String("commit", info.Commit),
+		attribute.String("build_time", info.BuildTime),
+		attribute.String("go_version", info.GoVersion),
+	)
+
+	_, err := meter.Int64ObservableGauge("build_info",
+		metric.WithDescription("Build information of the running binary, always 1."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(1, attrs)
+			return nil
+		}),
+	)
+	return err
+}
+
+func (s *Server) versionHandler(w http.ResponseWriter, _ *http.Request) {
+	_ = writeJSON(w, http.StatusOK, currentBuildInfo())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// =============================================================================
+// Build Info Tests
+// =============================================================================
+
+func TestCurrentBuildInfo_Fallbacks(t *testing.T) {
+	info := currentBuildInfo()
+
+	if info.Version == "" {
+		t.Error("expected version to fall back to a non-empty value")
+	}
+	if info.Commit == "" {
+		t.Error("expected commit to fall back to a non-empty value")
+	}
+	if info.BuildTime == "" {
+		t.Error("expected build time to fall back to a non-empty value")
+	}
+	if info.GoVersion == "" {
+		t.Error("expected go version to be set")
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	server := NewServer()
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rec := httptest.NewRecorder()
+
+	server.versionHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var result BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if result != currentBuildInfo() {
+		t.Errorf("expected %+v, got %+v", currentBuildInfo(), result)
+	}
+}
+
+func TestHealthHandler_IncludesBuildInfo(t *testing.T) {
+	server := NewServer()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+
+	server.healthHandler(rec, req)
+
+	var result map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if result["version"] != currentBuildInfo().Version {
+		t.Errorf("expected version %q, got %q", currentBuildInfo().Version, result["version"])
+	}
+	if result["commit"] != currentBuildInfo().Commit {
+		t.Errorf("expected commit %q, got %q", currentBuildInfo().Commit, result["commit"])
+	}
+}
+
+func TestRegisterBuildInfoMetric(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	if err := registerBuildInfoMetric(provider.Meter("test")); err != nil {
+		t.Fatalf("registerBuildInfoMetric returned error: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 1 {
+		t.Fatalf("expected exactly one metric, got %+v", rm.ScopeMetrics)
+	}
+
+	m := rm.ScopeMetrics[0].Metrics[0]
+	if m.Name != "build_info" {
+		t.Errorf("expected metric 'build_info', got %q", m.Name)
+	}
+
+	gauge, ok := m.Data.(metricdata.Gauge[int64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("expected a single int64 gauge data point, got %T", m.Data)
+	}
+
+	dp := gauge.DataPoints[0]
+	if dp.Value != 1 {
+		t.Errorf("expected value 1, got %d", dp.Value)
+	}
+	if v, _ := dp.Attributes.Value("version"); v.AsString() != currentBuildInfo().Version {
+		t.Errorf("expected version attribute %q, got %q", currentBuildInfo().Version, v.AsString())
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// supportedEncodings lists the values accepted in compression.encodings.
+var supportedEncodings = []string{"zstd", "gzip"}
+
+// encoder is the part of gzip.Writer and zstd.Encoder that compressWriter
+// uses, so both can be pooled and reset onto each response.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+	"zstd": {New: func() any {
+		// Browsers only decode windows up to 8 MiB, as RFC 8878 allows.
+		e, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+		if err != nil {
+			panic("invalid zstd options: " + err.Error())
+		}
+		return e
+	}},
+}
+
+// negotiateEncoding picks the content coding for a response from the request's
+// Accept-Encoding values: the offered coding with the highest q-value, the
+// earlier one in offered on a tie. It returns "" when the client accepts none
+// of them and the response should not be encoded.
+func negotiateEncoding(acceptEncoding []string, offered []string) string {
+	weights := map[string]float64{}
+	for _, value := range acceptEncoding {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				parsed, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					continue
+				}
+				q = parsed
+			}
+			weights[strings.ToLower(strings.TrimSpace(name))] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressibleType reports whether responses of contentType shrink enough to
+// be worth compressing. Event streams are left alone so each event reaches
+// the client as soon as it is flushed.
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml":
+		return true
+	}
+	return false
+}
+
+// compressionMiddleware compresses response bodies with the encoding
+// negotiated from Accept-Encoding. Bodies smaller than the configured minimum
+// are sent as they are, but every response that could have been compressed
+// carries Vary: Accept-Encoding so caches keep the variants apart. WebSocket
+// upgrades are passed through untouched.
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.compression.Load()
+		if len(cfg.Encodings) == 0 || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       negotiateEncoding(r.Header.Values("Accept-Encoding"), cfg.Encodings),
+			minSize:        cfg.MinSize,
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the status and the start of the body until it has
+// minSize bytes, the handler flushes, or the handler returns, and then decides
+// whether to compress. It wraps whatever writer it is given, including
+// loggingMiddleware's responseWriter, which sees the status as usual.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code < 200 {
+		// Informational responses go out immediately and are not the final
+		// status.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || cw.encoding == "" {
+		cw.decide()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide writes the held-back status, compressing the body from here on if
+// the response is eligible, and then the buffered start of the body.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff now; net/http would otherwise sniff the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	contentType := h.Get("Content-Type")
+	if h.Get("Content-Encoding") == "" && (contentType == "" || compressibleType(contentType)) {
+		addVary(h, "Accept-Encoding")
+		if cw.encoding != "" && cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+			len(cw.buf) > 0 && len(cw.buf) >= cw.minSize {
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			cw.enc = encoderPools[cw.encoding].Get().(encoder)
+			cw.enc.Reset(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// FlushError sends everything written so far, deciding on compression early
+// if need be. http.ResponseController prefers it to Flush.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+// Close finishes the response once the handler has returned.
+func (cw *compressWriter) Close() error {
+	var err error
+	if !cw.decided && cw.status != 0 {
+		err = cw.decide()
+	}
+	if cw.enc != nil {
+		if cerr := cw.enc.Close(); err == nil {
+			err = cerr
+		}
+		cw.enc.Reset(nil)
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
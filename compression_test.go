@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func decodeBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("invalid gzip body: %v", err)
+		}
+		r = zr
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("invalid zstd body: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return body
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s body: %v", encoding, err)
+	}
+	return out
+}
+
+func serveCompressed(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	server := newTestServerWithoutFaults()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	server.compressionMiddleware(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"zstd", "gzip"}
+	testCases := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"zstd;q=0, gzip", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"br, identity", ""},
+		{"GZIP", "gzip"},
+		{"gzip;q=bogus", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := negotiateEncoding([]string{tc.accept}, offered); got != tc.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+	if got := negotiateEncoding([]string{"zstd, gzip"}, []string{"gzip", "zstd"}); got != "gzip" {
+		t.Errorf("expected ties to follow the offered order, got %q", got)
+	}
+}
+
+func TestCompressibleType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json":                    true,
+		"application/problem+json":            true,
+		"application/vnd.menu.v2+json":        true,
+		"text/html; charset=utf-8":            true,
+		"text/event-stream":                   false,
+		"image/png":                           false,
+		"application/octet-stream":            false,
+		"":                                    false,
+		"application/json; charset=utf-8":     true,
+		"application/javascript; charset=utf": true,
+	} {
+		if got := compressibleType(contentType); got != want {
+			t.Errorf("compressibleType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestCompression_CompressesLargeBodies(t *testing.T) {
+	body := strings.Repeat(`{"name":"Margherita Pizza"},`, 100)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2700")
+		// Written in pieces to cross the threshold mid-body.
+		for chunk := range slices.Chunk([]byte(body), 100) {
+			_, _ = w.Write(chunk)
+		}
+	})
+
+	for _, encoding := range []string{"gzip", "zstd"} {
+		rec := serveCompressed(handler, encoding)
+		if got := rec.Header().Get("Content-Encoding"); got != encoding {
+			t.Fatalf("expected Content-Encoding %s, got %q", encoding, got)
+		}
+		if rec.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: Content-Length of the identity body must be dropped", encoding)
+		}
+		if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept-Encoding") {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %q", encoding, vary)
+		}
+		if rec.Body.Len() >= len(body) {
+			t.Errorf("%s: body did not shrink: %d bytes", encoding, rec.Body.Len())
+		}
+		if got := string(decodeBody(t, encoding, rec.Body.Bytes())); got != body {
+			t.Errorf("%s: body did not round-trip", encoding)
+		}
+	}
+}
+
+func TestCompression_LeavesIneligibleResponsesAlone(t *testing.T) {
+	large := strings.Repeat("a", 4096)
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		handler        http.HandlerFunc
+		wantVary       bool
+	}{
+		{
+			name:           "below minimum size",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":"healthy"}`))
+			},
+			wantVary: true,
+		},
+		{
+			name:           "client accepts no offered encoding",
+			acceptEncoding: "br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(large))
+			},
+			wantVary: true,
+		},
+		{
+			name:           "not modified",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			},
+			wantVary: true,
+		},
+		{
+			name:           "incompressible type",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = w.Write([]byte(large))
+			},
+		},
+		{
+			name:           "already encoded",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = w.Write([]byte(large))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveCompressed(tc.handler, tc.acceptEncoding)
+			if got := rec.Header().Get("Content-Encoding"); got != "" && got != "br" {
+				t.Errorf("expected no compression, got Content-Encoding %q", got)
+			}
+			if vary := slices.Contains(rec.Header().Values("Vary"), "Accept-Encoding"); vary != tc.wantVary {
+				t.Errorf("expected Vary: Accept-Encoding to be %v, got %q", tc.wantVary, rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCompression_KeepsStatusAndSniffsContentType(t *testing.T) {
+	html := "<!DOCTYPE html><html>" + strings.Repeat("<p>menu</p>", 200) + "</html>"
+	rec := serveCompressed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(html))
+	}), "gzip")
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected the body to be sniffed as HTML, got %q", ct)
+	}
+	if got := string(decodeBody(t, "gzip", rec.Body.Bytes())); got != html {
+		t.Error("body did not round-trip")
+	}
+}
+
+func TestCompression_EarlyFlushSendsIdentity(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	// Behind loggingMiddleware, as in production, so the flush has to find
+	// its way through responseWriter.Unwrap.
+	loggingMiddleware(server.compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush returned error: %v", err)
+		}
+		if !rec.Flushed || rec.Body.Len() == 0 {
+			t.Error("expected the flush to reach the client")
+		}
+		_, _ = w.Write([]byte(strings.Repeat(`"x",`, 512) + "}"))
+	}))).ServeHTTP(rec, req)
+
+	// The flush came before the minimum size, so the body stays identity.
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no encoding after an early flush, got %q", got)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "}") {
+		t.Error("expected the rest of the body after the flush")
+	}
+}
+
+func TestCompression_ThroughLoggingMiddleware(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	ts := httptest.NewServer(loggingMiddleware(server.inFlightMiddleware(newHTTPHandler(server))))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/openapi.json", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "zstd" {
+		t.Fatalf("expected a zstd 200, got %d with %q", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	if !bytes.HasPrefix(decodeBody(t, "zstd", body), []byte(`{"openapi":"3.0.3"`)) {
+		t.Error("expected the decoded body to be the OpenAPI document")
+	}
+}
+
+func TestCompression_Disabled(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.compression.Store(&CompressionConfig{MinSize: 0})
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newHTTPHandler(server).ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || slices.Contains(rec.Header().Values("Vary"), "Accept-Encoding") {
+		t.Errorf("expected compression to be off, got headers %v", rec.Header())
+	}
+}
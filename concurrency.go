@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// priorityShare is the fraction of the concurrency limit requests of each
+// priority may fill. Low priority requests are shed first, leaving the rest
+// of the limit to more important ones. Critical requests are never shed.
+var priorityShare = map[priority]float64{
+	priorityLow:     0.75,
+	priorityDefault: 0.9,
+	priorityHigh:    1,
+}
+
+// concurrencyLimiter sheds requests beyond an adaptive limit on how many are
+// handled at once, AIMD style: every request that finishes within the latency
+// threshold while at least half the limit is in use raises the limit by one,
+// and every slower one multiplies it by the backoff. Shed requests are
+// answered with a 503 straight away instead of queueing until WriteTimeout.
+type concurrencyLimiter struct {
+	config atomic.Pointer[ConcurrencyConfig]
+	shed   metric.Int64Counter
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+func newConcurrencyLimiter(cfg ConcurrencyConfig) (*concurrencyLimiter, error) {
+	shed, err := meter.Int64Counter("http.server.shed",
+		metric.WithDescription("HTTP requests shed by the concurrency limiter."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("shed request counter: %w", err)
+	}
+
+	l := &concurrencyLimiter{shed: shed, limit: float64(cfg.InitialLimit)}
+	l.config.Store(&cfg)
+	return l, nil
+}
+
+// reset applies cfg and restarts the limit from cfg.InitialLimit.
+func (l *concurrencyLimiter) reset(cfg ConcurrencyConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config.Store(&cfg)
+	l.limit = float64(cfg.InitialLimit)
+}
+
+// acquire admits a request of priority p, reporting false if it must be shed.
+// Admitted requests other than critical ones must be released.
+func (l *concurrencyLimiter) acquire(p priority) bool {
+	if p == priorityCritical {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inFlight) >= l.limit*priorityShare[p] {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release ends a request admitted by acquire that took latency, adjusting the
+// limit unless sample is false.
+func (l *concurrencyLimiter) release(latency time.Duration, sample bool) {
+	cfg := l.config.Load()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+	if !sample {
+		return
+	}
+	switch {
+	case latency > cfg.LatencyThreshold:
+		l.limit *= cfg.Backoff
+	case float64(inFlight)*2 >= l.limit:
+		l.limit++
+	}
+	l.limit = min(max(l.limit, float64(cfg.MinLimit)), float64(cfg.MaxLimit))
+}
+
+// current returns the limit and the requests it currently counts.
+func (l *concurrencyLimiter) current() (limit, inFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(math.Floor(l.limit)), l.inFlight
+}
+
+// limitRoute wraps the handler of rt in the concurrency limit. Streaming routes
+// are admitted or shed like any other, but give their slot back once
+// admitted, since they stay open for as long as the client wants.
+func (l *concurrencyLimiter) limitRoute(rt route, next http.Handler) http.Handler {
+	p, streaming := rt.priority(), rt.streaming()
+	if p == priorityCritical {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.config.Load().Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.acquire(p) {
+			l.shed.Add(r.Context(), 1, metric.WithAttributes(
+				attribute.String("http.route", rt.path()),
+				attribute.String("http.request.method", rt.method()),
+				attribute.String("priority", p.String()),
+			))
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.Bool("load.shed", true),
+				attribute.String("load.priority", p.String()),
+			)
+			addLogField(r.Context(), "shed", p.String())
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, errCodeOverloaded, "Server is overloaded, retry shortly")
+			return
+		}
+		if streaming {
+			l.release(0, false)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		defer func() { l.release(time.Since(start), true) }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerConcurrencyMetrics publishes the current concurrency limit and the
+// requests counted against it.
+func registerConcurrencyMetrics(meter metric.Meter, l *concurrencyLimiter) error {
+	limitGauge, err := meter.Int64ObservableGauge("http.server.concurrency.limit",
+		metric.WithDescription("Adaptive limit on HTTP requests handled at once."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return err
+	}
+	inFlightGauge, err := meter.Int64ObservableGauge("http.server.concurrency.in_flight",
+		metric.WithDescription("HTTP requests counted against the concurrency limit."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		limit, inFlight := l.current()
+		o.ObserveInt64(limitGauge, int64(limit))
+		o.ObserveInt64(inFlightGauge, int64(inFlight))
+		return nil
+	}, limitGauge, inFlightGauge)
+	return err
+}
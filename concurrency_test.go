@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestConcurrencyLimiter(t *testing.T, limit int) *concurrencyLimiter {
+	t.Helper()
+
+	cfg := defaultConfig().Concurrency
+	cfg.InitialLimit, cfg.MinLimit, cfg.MaxLimit = limit, 2, 100
+	l, err := newConcurrencyLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestRoutePriorities(t *testing.T) {
+	want := map[string]priority{
+		"GET /health":          priorityCritical,
+		"GET /readyz":          priorityCritical,
+		"GET /api/menu":        priorityLow,
+		"GET /api/v2/menu":     priorityLow,
+		"GET /api/menu/{id}":   priorityDefault,
+		"POST /graphql":        priorityDefault,
+		"GET /ws/tablets":      priorityHigh,
+		"GET /api/menu/stream": priorityDefault,
+	}
+	streaming := map[string]bool{"GET /ws/tablets": true, "GET /api/menu/stream": true}
+
+	for _, rt := range newTestServerWithoutFaults().routes() {
+		if p, ok := want[rt.pattern]; ok && rt.priority() != p {
+			t.Errorf("%s: expected priority %s, got %s", rt.pattern, p, rt.priority())
+		}
+		if rt.streaming() != streaming[rt.pattern] {
+			t.Errorf("%s: expected streaming to be %v", rt.pattern, streaming[rt.pattern])
+		}
+	}
+}
+
+func TestConcurrencyLimiter_ShedsLowPriorityFirst(t *testing.T) {
+	l := newTestConcurrencyLimiter(t, 10)
+
+	admitted := func(p priority) int {
+		n := 0
+		for l.acquire(p) {
+			n++
+		}
+		return n
+	}
+
+	if n := admitted(priorityLow); n != 8 {
+		t.Errorf("expected low priority to fill 75%% of 10 (8 requests), got %d", n)
+	}
+	if n := admitted(priorityDefault); n != 1 {
+		t.Errorf("expected default priority to fill up to 90%%, got %d more", n)
+	}
+	if n := admitted(priorityHigh); n != 1 {
+		t.Errorf("expected high priority to use the rest of the limit, got %d more", n)
+	}
+	for range 100 {
+		if !l.acquire(priorityCritical) {
+			t.Fatal("expected critical requests never to be shed")
+		}
+	}
+	if _, inFlight := l.current(); inFlight != 10 {
+		t.Errorf("expected critical requests not to be counted, got %d in flight", inFlight)
+	}
+}
+
+func TestConcurrencyLimiter_AIMD(t *testing.T) {
+	l := newTestConcurrencyLimiter(t, 10)
+	fast, slow := time.Millisecond, time.Second
+
+	// Growth needs the limit to be in use.
+	l.acquire(priorityHigh)
+	l.release(fast, true)
+	if limit, _ := l.current(); limit != 10 {
+		t.Errorf("expected an idle limit to stay at 10, got %d", limit)
+	}
+
+	for range 5 {
+		l.acquire(priorityHigh)
+	}
+	l.release(fast, true)
+	if limit, _ := l.current(); limit != 11 {
+		t.Errorf("expected a fast request to raise the limit to 11, got %d", limit)
+	}
+
+	l.release(slow, true)
+	if limit, _ := l.current(); limit != 9 {
+		t.Errorf("expected a slow request to cut the limit to 9 (11*0.9), got %d", limit)
+	}
+
+	l.release(0, false)
+	if limit, inFlight := l.current(); limit != 9 || inFlight != 2 {
+		t.Errorf("expected an unsampled release to leave the limit alone, got %d with %d in flight", limit, inFlight)
+	}
+
+	for range 50 {
+		l.acquire(priorityHigh)
+		l.release(slow, true)
+	}
+	if limit, _ := l.current(); limit != 2 {
+		t.Errorf("expected the limit to stop at min_limit, got %d", limit)
+	}
+}
+
+func TestConcurrencyLimiter_Returns503WhenOverloaded(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.concurrency.reset(ConcurrencyConfig{
+		Enabled: true, InitialLimit: 4, MinLimit: 4, MaxLimit: 4,
+		LatencyThreshold: time.Minute, Backoff: 0.5,
+	})
+	handler := newHTTPHandler(server)
+
+	// Fill the limit by hand rather than with slow requests.
+	for range 3 {
+		server.concurrency.acquire(priorityHigh)
+	}
+
+	start := time.Now()
+	rec := serveConditional(handler, "/api/menu", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the list endpoint to be shed with 503, got %d", rec.Code)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("expected shedding to be quick, took %s", time.Since(start))
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After on a shed request")
+	}
+	var body Problem
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/menu", nil)
+	req.Header.Set("Accept", problemContentType)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != errCodeOverloaded {
+		t.Errorf("expected an OVERLOADED problem, got %q", rec.Body.String())
+	}
+
+	for url, want := range map[string]int{
+		"/api/menu/1": http.StatusOK,
+		"/health":     http.StatusOK,
+		"/livez":      http.StatusOK,
+	} {
+		if rec := serveConditional(handler, url, nil); rec.Code != want {
+			t.Errorf("%s: expected status %d while lists are shed, got %d", url, want, rec.Code)
+		}
+	}
+
+	server.concurrency.config.Store(&ConcurrencyConfig{Enabled: false})
+	if rec := serveConditional(handler, "/api/menu", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected no shedding when disabled, got %d", rec.Code)
+	}
+}
+
+func TestConcurrencyLimiter_ReleasesAfterRequests(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	handler := newHTTPHandler(server)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() { serveConditional(handler, "/api/menu/1", nil) })
+	}
+	wg.Wait()
+
+	if _, inFlight := server.concurrency.current(); inFlight != 0 {
+		t.Errorf("expected every request to release its slot, got %d in flight", inFlight)
+	}
+}
+
+func TestConcurrencyLimiter_ExportsLimit(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	l := newTestConcurrencyLimiter(t, 42)
+	if err := registerConcurrencyMetrics(provider.Meter("test"), l); err != nil {
+		t.Fatal(err)
+	}
+	l.acquire(priorityDefault)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok && len(gauge.DataPoints) == 1 {
+				got[m.Name] = gauge.DataPoints[0].Value
+			}
+		}
+	}
+	if got["http.server.concurrency.limit"] != 42 || got["http.server.concurrency.in_flight"] != 1 {
+		t.Errorf("unexpected gauges %v", got)
+	}
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.yaml.in/yaml/v3"
+)
+
+const redacted = "REDACTED"
+
+// Config is the effective service configuration. Each setting is resolved
+// from, in increasing order of precedence: the defaults below, the YAML file
+// passed with -config (or CONFIG_FILE), the variable named by its env tag, and
+// a command-line flag named after its YAML path, e.g. -server.addr.
+//
+// Fields tagged secret:"true" are redacted by `config print`, and fields
+// tagged reload:"true" are picked up by a reload without restarting.
+type Config struct {
+	// File is the config file the settings were read from, if any.
+	File string `yaml:"-"`
+
+	Server      ServerConfig      `yaml:"server"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	Admin       AdminConfig       `yaml:"admin"`
+	Orders      OrdersConfig      `yaml:"orders"`
+	GraphQL     GraphQLConfig     `yaml:"graphql"`
+	HTTPCache   HTTPCacheConfig   `yaml:"http_cache"`
+	MenuCache   MenuCacheConfig   `yaml:"menu_cache"`
+	Compression CompressionConfig `yaml:"compression"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	Timeouts    TimeoutConfig     `yaml:"timeouts"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Shutdown    ShutdownConfig    `yaml:"shutdown"`
+	Log         LogConfig         `yaml:"log"`
+	Telemetry   TelemetryConfig   `yaml:"telemetry"`
+	Faults      FaultConfig       `yaml:"faults"`
+}
+
+type ServerConfig struct {
+	Addr         string        `yaml:"addr" env:"LISTEN_ADDR" usage:"HTTP listen address"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" usage:"maximum duration for reading a request"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" usage:"maximum duration before timing out a response write"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" usage:"keep-alive idle timeout"`
+	Protocols    []string      `yaml:"protocols" env:"SERVER_PROTOCOLS" usage:"protocols served: http1, h2c (cleartext HTTP/2), h2 (HTTP/2 over TLS)"`
+	TLS          TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig enables TLS on a listener when CertFile is set. Certificate and
+// key files are re-read when they change on disk.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE" usage:"PEM certificate chain; enables TLS"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE" usage:"PEM private key for cert_file"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// GRPCConfig configures the gRPC listener, which shares server.tls when TLS
+// is enabled.
+type GRPCConfig struct {
+	Addr string `yaml:"addr" env:"GRPC_LISTEN_ADDR" usage:"gRPC listen address; empty disables the gRPC server"`
+}
+
+func (c GRPCConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+// AdminConfig configures the admin listener, which serves the health checks,
+// /version and the API docs apart from the public routes. It shares
+// server.tls when TLS is enabled and can require client certificates, which
+// the public listener never asks for.
+type AdminConfig struct {
+	Addr         string `yaml:"addr" env:"ADMIN_LISTEN_ADDR" usage:"admin listen address; empty disables the admin listener"`
+	ClientCAFile string `yaml:"client_ca_file" env:"ADMIN_CLIENT_CA_FILE" usage:"PEM CA bundle used to verify client certificates on the admin listener"`
+	ClientAuth   string `yaml:"client_auth" env:"ADMIN_CLIENT_AUTH" usage:"client certificate policy of the admin listener (none, request, require)"`
+}
+
+func (c AdminConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+type OrdersConfig struct {
+	SimulateInterval time.Duration `yaml:"simulate_interval" env:"ORDERS_SIMULATE_INTERVAL" usage:"place a random order for restaurant tablets this often; 0 disables"`
+}
+
+// GraphQLConfig bounds the cost of a single /graphql operation.
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" usage:"maximum field nesting of a GraphQL operation" reload:"true"`
+	MaxComplexity int `yaml:"max_complexity" env:"GRAPHQL_MAX_COMPLEXITY" usage:"maximum estimated fields resolved by a GraphQL operation" reload:"true"`
+}
+
+type ShutdownConfig struct {
+	// PreStopDelay is how long readiness reports failure before the listener
+	// closes, giving kube-proxy and the ingress time to stop routing to us.
+	PreStopDelay time.Duration `yaml:"pre_stop_delay" env:"SHUTDOWN_PRESTOP_DELAY" usage:"time readiness fails before the listener closes"`
+	// Timeout bounds the whole drain, including PreStopDelay. It should stay
+	// below the pod's terminationGracePeriodSeconds.
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT" usage:"total shutdown budget"`
+	// TelemetryFlush is set aside at the end of Timeout for flushing spans
+	// and metrics, so servers slow to stop cannot use it up.
+	TelemetryFlush time.Duration `yaml:"telemetry_flush" env:"SHUTDOWN_TELEMETRY_FLUSH" usage:"part of the shutdown budget reserved for flushing telemetry"`
+}
+
+// HTTPCacheConfig sets the Cache-Control of cacheable menu responses.
+// Browsers use MaxAge; the ingress and CDN use SharedMaxAge and may serve a
+// stale copy for StaleWhileRevalidate while they revalidate with an ETag.
+type HTTPCacheConfig struct {
+	MaxAge               time.Duration `yaml:"max_age" env:"HTTP_CACHE_MAX_AGE" reload:"true" usage:"max-age of cacheable responses"`
+	SharedMaxAge         time.Duration `yaml:"shared_max_age" env:"HTTP_CACHE_SHARED_MAX_AGE" reload:"true" usage:"s-maxage of cacheable responses, for shared caches"`
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate" env:"HTTP_CACHE_STALE_WHILE_REVALIDATE" reload:"true" usage:"stale-while-revalidate of cacheable responses"`
+}
+
+// MenuCacheConfig bounds the in-process cache in front of the menu store. A
+// zero TTL reads through to the store on every call.
+type MenuCacheConfig struct {
+	TTL        time.Duration `yaml:"ttl" env:"MENU_CACHE_TTL" reload:"true" usage:"how long menu store reads are cached (0 disables)"`
+	MaxEntries int           `yaml:"max_entries" env:"MENU_CACHE_MAX_ENTRIES" reload:"true" usage:"maximum number of cached menu store reads"`
+}
+
+// CompressionConfig selects how HTTP responses are compressed. Encodings are
+// in order of preference for clients that accept several equally; an empty
+// list disables compression.
+type CompressionConfig struct {
+	Encodings []string `yaml:"encodings" env:"COMPRESSION_ENCODINGS" reload:"true" usage:"response encodings offered, in order of preference: zstd, gzip"`
+	MinSize   int      `yaml:"min_size" env:"COMPRESSION_MIN_SIZE" reload:"true" usage:"smallest response body in bytes that is compressed"`
+}
+
+// RateLimitConfig sets token-bucket limits on the HTTP routes. A limit reads
+// "<requests>/<s|m|h>", optionally followed by "burst=<n>" (default: the
+// requests per period) and "by=ip|api_key|route" (default: ip), e.g.
+// "20/s burst=40 by=api_key"; "off" removes it. Routes are keyed by their
+// pattern, e.g. "GET /api/menu", and replace the default.
+type RateLimitConfig struct {
+	Default        string            `yaml:"default" env:"RATE_LIMIT_DEFAULT" reload:"true" usage:"limit for routes without their own, e.g. 100/s burst=200 (empty or off disables)"`
+	Routes         map[string]string `yaml:"routes" env:"RATE_LIMIT_ROUTES" reload:"true" usage:"limits by route pattern, as GET /api/menu=50/s burst=100,..."`
+	TrustedProxies []string          `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES" reload:"true" usage:"addresses or CIDRs of proxies whose X-Forwarded-For is trusted, e.g. the ingress"`
+}
+
+// ConcurrencyConfig tunes the adaptive limit on requests handled at once. The
+// limit grows by one while requests finish within LatencyThreshold and the
+// limit is in use, and shrinks by Backoff when one takes longer.
+type ConcurrencyConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"CONCURRENCY_LIMIT_ENABLED" reload:"true" usage:"shed requests beyond the adaptive concurrency limit"`
+	InitialLimit     int           `yaml:"initial_limit" env:"CONCURRENCY_INITIAL_LIMIT" usage:"concurrency limit at startup"`
+	MinLimit         int           `yaml:"min_limit" env:"CONCURRENCY_MIN_LIMIT" reload:"true" usage:"lowest the concurrency limit may fall"`
+	MaxLimit         int           `yaml:"max_limit" env:"CONCURRENCY_MAX_LIMIT" reload:"true" usage:"highest the concurrency limit may grow"`
+	LatencyThreshold time.Duration `yaml:"latency_threshold" env:"CONCURRENCY_LATENCY_THRESHOLD" reload:"true" usage:"request latency above which the limit shrinks"`
+	Backoff          float64       `yaml:"backoff" env:"CONCURRENCY_BACKOFF" reload:"true" usage:"factor the limit is multiplied by when it shrinks"`
+}
+
+// TimeoutConfig sets how long an HTTP route may take to answer. The budget
+// is carried in the request context down to the menu store and order
+// dispatch, and a request that runs out of it gets a 504. Routes are keyed by
+// their pattern, e.g. "GET /api/menu", and replace the default; a zero budget
+// disables the timeout. Streaming routes have none.
+type TimeoutConfig struct {
+	Default time.Duration     `yaml:"default" env:"TIMEOUT_DEFAULT" reload:"true" usage:"time budget of routes without their own (0 disables)"`
+	Routes  map[string]string `yaml:"routes" env:"TIMEOUT_ROUTES" reload:"true" usage:"time budgets by route pattern, as GET /api/menu=2s,..."`
+}
+
+// AuthConfig turns on API key authentication once any key is configured;
+// until then every route is anonymous. Keys are kept as the SHA-256 of their
+// secret, never the secret itself: APIKeys maps a key ID to
+// "sha256:<hex> <scope>...", and APIKeysFile holds one
+// "<id> sha256:<hex> <scope>..." line per key and is re-read when it changes.
+//
+// Setting JWKSFile or JWKSURL also accepts bearer JWTs signed by the identity
+// provider's keys, which are cached and refetched every JWKSRefresh.
+type AuthConfig struct {
+	APIKeysFile     string            `yaml:"api_keys_file" env:"AUTH_API_KEYS_FILE" usage:"file of hashed API keys, one <id> sha256:<hex> <scope>... per line"`
+	APIKeys         map[string]string `yaml:"api_keys" env:"AUTH_API_KEYS" secret:"true" reload:"true" usage:"hashed API keys by ID, as id=sha256:<hex> <scope>...,..."`
+	AnonymousScopes []string          `yaml:"anonymous_scopes" env:"AUTH_ANONYMOUS_SCOPES" reload:"true" usage:"scopes of requests without an API key once keys are configured"`
+	JWKSFile        string            `yaml:"jwks_file" env:"AUTH_JWKS_FILE" reload:"true" usage:"JWKS file of the identity provider; enables bearer tokens"`
+	JWKSURL         string            `yaml:"jwks_url" env:"AUTH_JWKS_URL" reload:"true" usage:"JWKS URL of the identity provider; enables bearer tokens"`
+	JWKSRefresh     time.Duration     `yaml:"jwks_refresh" env:"AUTH_JWKS_REFRESH" reload:"true" usage:"how often the JWKS is reloaded"`
+	Issuer          string            `yaml:"issuer" env:"AUTH_ISSUER" reload:"true" usage:"iss bearer tokens must carry (empty accepts any)"`
+	Audience        string            `yaml:"audience" env:"AUTH_AUDIENCE" reload:"true" usage:"aud bearer tokens must include (empty accepts any)"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" reload:"true" usage:"minimum log level (trace, debug, info, warn, error)"`
+}
+
+type TelemetryConfig struct {
+	OTLPEndpoint string            `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP gRPC collector endpoint"`
+	OTLPHeaders  map[string]string `yaml:"otlp_headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true" usage:"headers sent with every export, as k=v,k2=v2"`
+}
+
+type FaultConfig struct {
+	// MenuErrorRate is the probability that listing the menu fails with a
+	// simulated database error.
+	MenuErrorRate float64 `yaml:"menu_error_rate" env:"FAULT_MENU_ERROR_RATE" reload:"true" usage:"probability of a simulated menu listing failure"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:         ":8080",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+			Protocols:    []string{"http1", "h2"},
+		},
+		GRPC: GRPCConfig{
+			Addr: ":9090",
+		},
+		Admin: AdminConfig{
+			ClientAuth: "none",
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      6,
+			MaxComplexity: 1000,
+		},
+		HTTPCache: HTTPCacheConfig{
+			MaxAge:               10 * time.Second,
+			SharedMaxAge:         30 * time.Second,
+			StaleWhileRevalidate: 30 * time.Second,
+		},
+		MenuCache: MenuCacheConfig{
+			TTL:        5 * time.Second,
+			MaxEntries: 1000,
+		},
+		Compression: CompressionConfig{
+			Encodings: []string{"zstd", "gzip"},
+			MinSize:   1024,
+		},
+		RateLimit: RateLimitConfig{
+			Default: "100/s burst=200",
+		},
+		Concurrency: ConcurrencyConfig{
+			Enabled:          true,
+			InitialLimit:     50,
+			MinLimit:         5,
+			MaxLimit:         500,
+			LatencyThreshold: 500 * time.Millisecond,
+			Backoff:          0.9,
+		},
+		Timeouts: TimeoutConfig{
+			Default: 2 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWKSRefresh: 5 * time.Minute,
+		},
+		Shutdown: ShutdownConfig{
+			PreStopDelay:   5 * time.Second,
+			Timeout:        25 * time.Second,
+			TelemetryFlush: 5 * time.Second,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Telemetry: TelemetryConfig{
+			OTLPEndpoint: "localhost:4317",
+		},
+		Faults: FaultConfig{
+			MenuErrorRate: 0.1,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{field}, args...)...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		invalid("server.addr", "%v", err)
+	}
+	for field, d := range map[string]time.Duration{
+		"server.read_timeout":  c.Server.ReadTimeout,
+		"server.write_timeout": c.Server.WriteTimeout,
+		"server.idle_timeout":  c.Server.IdleTimeout,
+		"shutdown.timeout":     c.Shutdown.Timeout,
+	} {
+		if d <= 0 {
+			invalid(field, "must be positive, got %s", d)
+		}
+	}
+	c.Server.TLS.validate("server.tls", invalid)
+	if len(c.Server.Protocols) == 0 {
+		invalid("server.protocols", "at least one protocol is required")
+	}
+	for _, p := range c.Server.Protocols {
+		if !slices.Contains(supportedProtocols, p) {
+			invalid("server.protocols", "unknown protocol %q, expected one of %s", p, strings.Join(supportedProtocols, ", "))
+		}
+	}
+	if !c.Server.TLS.Enabled() && !slices.Contains(c.Server.Protocols, "http1") && !slices.Contains(c.Server.Protocols, "h2c") {
+		invalid("server.protocols", "h2 requires server.tls; enable http1 or h2c to serve cleartext")
+	}
+	if c.GRPC.Enabled() {
+		if _, _, err := net.SplitHostPort(c.GRPC.Addr); err != nil {
+			invalid("grpc.addr", "%v", err)
+		}
+	}
+	c.Admin.validate(c, invalid)
+	if c.Orders.SimulateInterval < 0 {
+		invalid("orders.simulate_interval", "must not be negative, got %s", c.Orders.SimulateInterval)
+	}
+	if c.GraphQL.MaxDepth < 1 {
+		invalid("graphql.max_depth", "must be at least 1, got %d", c.GraphQL.MaxDepth)
+	}
+	if c.GraphQL.MaxComplexity < 1 {
+		invalid("graphql.max_complexity", "must be at least 1, got %d", c.GraphQL.MaxComplexity)
+	}
+	for field, d := range map[string]time.Duration{
+		"http_cache.max_age":                c.HTTPCache.MaxAge,
+		"http_cache.shared_max_age":         c.HTTPCache.SharedMaxAge,
+		"http_cache.stale_while_revalidate": c.HTTPCache.StaleWhileRevalidate,
+	} {
+		if d < 0 {
+			invalid(field, "must not be negative, got %s", d)
+		}
+	}
+	if c.MenuCache.TTL < 0 {
+		invalid("menu_cache.ttl", "must not be negative, got %s", c.MenuCache.TTL)
+	}
+	if c.MenuCache.MaxEntries < 1 {
+		invalid("menu_cache.max_entries", "must be at least 1, got %d", c.MenuCache.MaxEntries)
+	}
+	for _, e := range c.Compression.Encodings {
+		if !slices.Contains(supportedEncodings, e) {
+			invalid("compression.encodings", "unknown encoding %q, expected one of %s", e, strings.Join(supportedEncodings, ", "))
+		}
+	}
+	if c.Compression.MinSize < 0 {
+		invalid("compression.min_size", "must not be negative, got %d", c.Compression.MinSize)
+	}
+	if _, err := newRateLimitPolicy(c.RateLimit); err != nil {
+		invalid("rate_limit", "%v", err)
+	}
+	if cc := c.Concurrency; cc.MinLimit < 1 {
+		invalid("concurrency.min_limit", "must be at least 1, got %d", cc.MinLimit)
+	} else if cc.MaxLimit < cc.MinLimit {
+		invalid("concurrency.max_limit", "must be at least concurrency.min_limit (%d), got %d", cc.MinLimit, cc.MaxLimit)
+	} else if cc.InitialLimit < cc.MinLimit || cc.InitialLimit > cc.MaxLimit {
+		invalid("concurrency.initial_limit", "must be between %d and %d, got %d", cc.MinLimit, cc.MaxLimit, cc.InitialLimit)
+	}
+	if c.Concurrency.LatencyThreshold <= 0 {
+		invalid("concurrency.latency_threshold", "must be positive, got %s", c.Concurrency.LatencyThreshold)
+	}
+	if b := c.Concurrency.Backoff; b <= 0 || b >= 1 {
+		invalid("concurrency.backoff", "must be between 0 and 1, got %v", b)
+	}
+	if policy, err := newTimeoutPolicy(c.Timeouts); err != nil {
+		invalid("timeouts", "%v", err)
+	} else if longest := policy.longest(); longest >= c.Server.WriteTimeout {
+		invalid("timeouts", "budgets must be shorter than server.write_timeout (%s) to answer with a 504, got %s", c.Server.WriteTimeout, longest)
+	}
+	if _, err := loadAPIKeys(c.Auth); err != nil {
+		invalid("auth", "%v", err)
+	}
+	if err := validateJWKSSource(c.Auth); err != nil {
+		invalid("auth", "%v", err)
+	}
+	if c.Shutdown.PreStopDelay < 0 {
+		invalid("shutdown.pre_stop_delay", "must not be negative, got %s", c.Shutdown.PreStopDelay)
+	} else if c.Shutdown.PreStopDelay >= c.Shutdown.Timeout {
+		invalid("shutdown.pre_stop_delay", "must be shorter than shutdown.timeout (%s), got %s", c.Shutdown.Timeout, c.Shutdown.PreStopDelay)
+	}
+	if c.Shutdown.TelemetryFlush < 0 {
+		invalid("shutdown.telemetry_flush", "must not be negative, got %s", c.Shutdown.TelemetryFlush)
+	} else if c.Shutdown.PreStopDelay >= 0 && c.Shutdown.PreStopDelay+c.Shutdown.TelemetryFlush >= c.Shutdown.Timeout {
+		invalid("shutdown.telemetry_flush", "must leave time for the servers to stop within shutdown.timeout (%s) after shutdown.pre_stop_delay (%s), got %s",
+			c.Shutdown.Timeout, c.Shutdown.PreStopDelay, c.Shutdown.TelemetryFlush)
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil || c.Log.Level == "" {
+		invalid("log.level", "unknown level %q", c.Log.Level)
+	}
+	if c.Telemetry.OTLPEndpoint == "" {
+		invalid("telemetry.otlp_endpoint", "must not be empty")
+	}
+	if r := c.Faults.MenuErrorRate; r < 0 || r > 1 {
+		invalid("faults.menu_error_rate", "must be between 0 and 1, got %v", r)
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+func (c TLSConfig) validate(prefix string, invalid func(field, format string, args ...any)) {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		invalid(prefix+".key_file", "cert_file and key_file must be set together")
+	}
+}
+
+func (c AdminConfig) validate(cfg Config, invalid func(field, format string, args ...any)) {
+	if c.Enabled() {
+		if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+			invalid("admin.addr", "%v", err)
+		}
+		if c.Addr == cfg.Server.Addr || cfg.GRPC.Enabled() && c.Addr == cfg.GRPC.Addr {
+			invalid("admin.addr", "must differ from server.addr and grpc.addr")
+		}
+	}
+	if _, ok := clientAuthTypes[c.ClientAuth]; !ok {
+		invalid("admin.client_auth", "unknown policy %q", c.ClientAuth)
+	}
+	if c.ClientAuth != "none" && c.ClientCAFile == "" {
+		invalid("admin.client_ca_file", "required when client_auth is %q", c.ClientAuth)
+	}
+	if c.ClientCAFile != "" && !cfg.Server.TLS.Enabled() {
+		invalid("admin.client_ca_file", "requires server.tls.cert_file to enable TLS")
+	}
+}
+
+// Redacted returns a copy of the configuration that is safe to print.
+func (c Config) Redacted() Config {
+	out := c
+	walkConfig(&out, func(f configField) {
+		if !f.secret || f.value.IsZero() {
+			return
+		}
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(redacted)
+		case reflect.Map:
+			// Keep the keys so operators can see which headers are set. The map
+			// is replaced rather than mutated since it is shared with c.
+			m := reflect.MakeMap(f.value.Type())
+			for _, k := range f.value.MapKeys() {
+				m.SetMapIndex(k, reflect.ValueOf(redacted))
+			}
+			f.value.Set(m)
+		}
+	})
+	return out
+}
+
+// configField is a leaf setting discovered by walkConfig.
+type configField struct {
+	path       string
+	env        string
+	usage      string
+	secret     bool
+	reloadable bool
+	value      reflect.Value
+}
+
+func walkConfig(cfg *Config, fn func(configField)) {
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path)
+				continue
+			}
+			fn(configField{
+				path:       path,
+				env:        sf.Tag.Get("env"),
+				usage:      sf.Tag.Get("usage"),
+				secret:     sf.Tag.Get("secret") == "true",
+				reloadable: sf.Tag.Get("reload") == "true",
+				value:      v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+}
+
+// setFromString parses raw into a leaf config value according to its type.
+func setFromString(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String:
+		m := map[string]string{}
+		for _, pair := range strings.Split(raw, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// flagOverride records a flag value so it can be applied after the file and
+// environment, regardless of where it appeared on the command line.
+type flagOverride struct {
+	raw string
+	set bool
+}
+
+func (f *flagOverride) String() string { return f.raw }
+
+func (f *flagOverride) Set(raw string) error {
+	f.raw, f.set = raw, true
+	return nil
+}
+
+// loadConfig resolves the effective configuration from defaults, the config
+// file, environment and flags, then validates it.
+func loadConfig(name string, args []string, getenv func(string) string) (Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML config file")
+	overrides := map[string]*flagOverride{}
+	walkConfig(&cfg, func(f configField) {
+		overrides[f.path] = &flagOverride{}
+		usage := f.usage
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		fs.Var(overrides[f.path], f.path, usage)
+	})
+	if err := fs.Parse(args); err != nil {
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.PrintDefaults()
+		return cfg, fmt.Errorf("%w\n\nFlags:\n%s", err, buf.String())
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := decodeConfigFile(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing config file %s: %w", *configFile, err)
+		}
+		cfg.File = *configFile
+	}
+
+	var errs []error
+	walkConfig(&cfg, func(f configField) {
+		if f.env == "" {
+			return
+		}
+		if raw := getenv(f.env); raw != "" {
+			if err := setFromString(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid %s: %w", f.path, f.env, err))
+			}
+		}
+	})
+	walkConfig(&cfg, func(f configField) {
+		if o := overrides[f.path]; o.set {
+			if err := setFromString(f.value, o.raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid flag value: %w", f.path, err))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// decodeConfigFile overlays a YAML document onto cfg, rejecting unknown keys
+// so typos do not silently fall back to defaults.
+func decodeConfigFile(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// printConfig writes the configuration as YAML with secrets redacted.
+func printConfig(w io.Writer, cfg Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envMap(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// =============================================================================
+// loadConfig Tests
+// =============================================================================
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	cfg, err := loadConfig("test", nil, envMap(nil))
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	defaults := defaultConfig()
+	if !reflect.DeepEqual(cfg.Server, defaults.Server) {
+		t.Errorf("expected server defaults %+v, got %+v", defaults.Server, cfg.Server)
+	}
+	if cfg.Server.Addr != ":8080" {
+		t.Errorf("expected default addr ':8080', got %q", cfg.Server.Addr)
+	}
+	if cfg.Faults.MenuErrorRate != 0.1 {
+		t.Errorf("expected default menu error rate 0.1, got %v", cfg.Faults.MenuErrorRate)
+	}
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: ":9000"
+  read_timeout: 3s
+  write_timeout: 4s
+log:
+  level: debug
+faults:
+  menu_error_rate: 0.5
+`)
+
+	env := envMap(map[string]string{
+		"CONFIG_FILE":          path,
+		"SERVER_WRITE_TIMEOUT": "5s",
+		"LOG_LEVEL":            "warn",
+	})
+	args := []string{"-log.level=error"}
+
+	cfg, err := loadConfig("test", args, env)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"file over default", cfg.Server.Addr, ":9000"},
+		{"file only", cfg.Server.ReadTimeout, 3 * time.Second},
+		{"env over file", cfg.Server.WriteTimeout, 5 * time.Second},
+		{"flag over env", cfg.Log.Level, "error"},
+		{"default kept", cfg.Server.IdleTimeout, 60 * time.Second},
+		{"file float", cfg.Faults.MenuErrorRate, 0.5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, tc.got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ConfigFlagOverridesEnvPath(t *testing.T) {
+	envPath := writeConfigFile(t, "server:\n  addr: \":7000\"\n")
+	flagPath := writeConfigFile(t, "server:\n  addr: \":7001\"\n")
+
+	cfg, err := loadConfig("test", []string{"-config", flagPath}, envMap(map[string]string{"CONFIG_FILE": envPath}))
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if cfg.Server.Addr != ":7001" {
+		t.Errorf("expected addr from -config file, got %q", cfg.Server.Addr)
+	}
+}
+
+func TestLoadConfig_MapFromEnv(t *testing.T) {
+	env := envMap(map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "authorization=Bearer abc, x-tenant=prod"})
+
+	cfg, err := loadConfig("test", nil, env)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+
+	if cfg.Telemetry.OTLPHeaders["authorization"] != "Bearer abc" {
+		t.Errorf("expected authorization header, got %v", cfg.Telemetry.OTLPHeaders)
+	}
+	if cfg.Telemetry.OTLPHeaders["x-tenant"] != "prod" {
+		t.Errorf("expected x-tenant header, got %v", cfg.Telemetry.OTLPHeaders)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		file     string
+		contains string
+	}{
+		{"unknown flag", []string{"-nope"}, nil, "", "flag provided but not defined"},
+		{"bad duration flag", []string{"-server.read_timeout=fast"}, nil, "", "server.read_timeout"},
+		{"bad env", nil, map[string]string{"FAULT_MENU_ERROR_RATE": "often"}, "", "FAULT_MENU_ERROR_RATE"},
+		{"unknown file key", nil, nil, "server:\n  adress: \":1\"\n", "adress"},
+		{"missing file", []string{"-config=/does/not/exist.yaml"}, nil, "", "reading config file"},
+		{"invalid value", []string{"-faults.menu_error_rate=2"}, nil, "", "faults.menu_error_rate"},
+		{"extra args", []string{"serve"}, nil, "", "unexpected arguments"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			if tc.file != "" {
+				env["CONFIG_FILE"] = writeConfigFile(t, tc.file)
+			}
+
+			_, err := loadConfig("test", tc.args, envMap(env))
+
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("expected error containing %q, got %q", tc.contains, err.Error())
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Config.Validate Tests
+// =============================================================================
+
+func TestConfigValidate_ReportsAllErrors(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Server.Addr = "8080"
+	cfg.Server.ReadTimeout = 0
+	cfg.Shutdown.PreStopDelay = 30 * time.Second
+	cfg.Log.Level = "loud"
+	cfg.Faults.MenuErrorRate = -0.1
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+
+	for _, field := range []string{"server.addr", "server.read_timeout", "shutdown.pre_stop_delay", "log.level", "faults.menu_error_rate"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error to mention %q, got %q", field, err.Error())
+		}
+	}
+}
+
+func TestConfigValidate_TelemetryFlushFitsShutdown(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Shutdown.TelemetryFlush = cfg.Shutdown.Timeout - cfg.Shutdown.PreStopDelay
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "shutdown.telemetry_flush") {
+		t.Errorf("expected shutdown.telemetry_flush to be rejected, got %v", err)
+	}
+}
+
+func TestConfigValidate_Defaults(t *testing.T) {
+	if err := defaultConfig().Validate(); err != nil {
+		t.Errorf("expected defaults to be valid, got %v", err)
+	}
+}
+
+// =============================================================================
+// config print Tests
+// =============================================================================
+
+func TestPrintConfig_RedactsSecrets(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Telemetry.OTLPHeaders = map[string]string{"authorization": "Bearer s3cr3t"}
+
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		t.Fatalf("printConfig returned error: %v", err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "s3cr3t") {
+		t.Errorf("expected secret to be redacted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "authorization: "+redacted) {
+		t.Errorf("expected redacted header key to be listed, got:\n%s", out)
+	}
+	if !strings.Contains(out, "read_timeout: 10s") {
+		t.Errorf("expected durations in Go syntax, got:\n%s", out)
+	}
+	if cfg.Telemetry.OTLPHeaders["authorization"] != "Bearer s3cr3t" {
+		t.Error("expected Redacted not to modify the original config")
+	}
+}
+
+func TestPrintConfig_RoundTrips(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Server.Addr = ":9090"
+
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		t.Fatalf("printConfig returned error: %v", err)
+	}
+
+	parsed := defaultConfig()
+	if err := decodeConfigFile(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("printed config is not loadable: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Server, cfg.Server) {
+		t.Errorf("expected %+v, got %+v", cfg.Server, parsed.Server)
+	}
+}
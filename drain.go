@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const inFlightLogInterval = time.Second
+
+// shutdownStep is one component stopped during drain, in order. reserve is
+// set aside for the step at the end of the budget: the steps before it must
+// finish that much earlier, so a stuck server cannot use it up.
+type shutdownStep struct {
+	name    string
+	fn      func(context.Context) error
+	reserve time.Duration
+}
+
+func (s *Server) inFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drain flips readiness to failing, waits out the pre-stop delay, ends
+// long-lived streams and then runs each shutdown step in order within the
+// overall budget, less what later steps reserve. A failing step is logged and
+// does not prevent later steps from running.
+func (s *Server) drain(cfg ShutdownConfig, steps ...shutdownStep) error {
+	deadline := time.Now().Add(cfg.Timeout)
+	var reserved time.Duration
+	for _, step := range steps {
+		reserved += step.reserve
+	}
+
+	preStop, cancel := context.WithDeadline(context.Background(), deadline.Add(-reserved))
+	defer cancel()
+
+	s.draining.Store(true)
+	log.Info().
+		Dur("pre_stop_delay", cfg.PreStopDelay).
+		Dur("timeout", cfg.Timeout).
+		Int64("in_flight", s.inFlight.Load()).
+		Msg("Draining: readiness is now failing")
+
+	select {
+	case <-time.After(cfg.PreStopDelay):
+	case <-preStop.Done():
+	}
+
+	close(s.closing)
+
+	stopLogging := s.logInFlight(inFlightLogInterval)
+	defer stopLogging()
+
+	var errs error
+	for _, step := range steps {
+		reserved -= step.reserve
+		stepCtx, cancelStep := context.WithDeadline(context.Background(), deadline.Add(-reserved))
+		start := time.Now()
+		err := step.fn(stepCtx)
+		cancelStep()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("step", step.name).
+				Int64("in_flight", s.inFlight.Load()).
+				Dur("duration", time.Since(start)).
+				Msg("Shutdown step failed")
+			errs = errors.Join(errs, err)
+			continue
+		}
+		log.Info().
+			Str("step", step.name).
+			Dur("duration", time.Since(start)).
+			Msg("Shutdown step completed")
+	}
+
+	return errs
+}
+
+// logInFlight periodically reports requests still being served until the
+// returned stop function is called.
+func (s *Server) logInFlight(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if n := s.inFlight.Load(); n > 0 {
+					log.Info().Int64("in_flight", n).Msg("Waiting for in-flight requests")
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
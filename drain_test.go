@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Drain Tests
+// =============================================================================
+
+func TestDrain_FlipsReadinessBeforeSteps(t *testing.T) {
+	server := NewServer()
+
+	var readyDuringStep bool
+	err := server.drain(ShutdownConfig{Timeout: time.Second},
+		shutdownStep{name: "probe", fn: func(ctx context.Context) error {
+			healthy, _ := server.health.run(ctx, probeReadiness)
+			readyDuringStep = healthy
+			return nil
+		}},
+	)
+
+	if err != nil {
+		t.Fatalf("drain returned error: %v", err)
+	}
+	if !server.draining.Load() {
+		t.Error("expected server to be marked as draining")
+	}
+	if readyDuringStep {
+		t.Error("expected readiness to fail before shutdown steps run")
+	}
+}
+
+func TestDrain_WaitsPreStopDelay(t *testing.T) {
+	server := NewServer()
+	delay := 50 * time.Millisecond
+
+	start := time.Now()
+	var stepStarted time.Duration
+	_ = server.drain(ShutdownConfig{PreStopDelay: delay, Timeout: time.Second},
+		shutdownStep{name: "http_server", fn: func(context.Context) error {
+			stepStarted = time.Since(start)
+			return nil
+		}},
+	)
+
+	if stepStarted < delay {
+		t.Errorf("expected first step after %v, started after %v", delay, stepStarted)
+	}
+}
+
+func TestDrain_RunsStepsInOrderAndJoinsErrors(t *testing.T) {
+	server := NewServer()
+
+	var order []string
+	step := func(name string, err error) shutdownStep {
+		return shutdownStep{name: name, fn: func(context.Context) error {
+			order = append(order, name)
+			return err
+		}}
+	}
+
+	boom := errors.New("boom")
+	err := server.drain(ShutdownConfig{Timeout: time.Second},
+		step("http_server", nil),
+		step("workers", boom),
+		step("telemetry", nil),
+	)
+
+	if !errors.Is(err, boom) {
+		t.Errorf("expected joined error to contain %v, got %v", boom, err)
+	}
+
+	expected := []string{"http_server", "workers", "telemetry"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected steps %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("step %d: expected %q, got %q", i, expected[i], order[i])
+		}
+	}
+}
+
+func TestDrain_BudgetBoundsPreStopDelay(t *testing.T) {
+	server := NewServer()
+
+	var stepErr error
+	start := time.Now()
+	_ = server.drain(ShutdownConfig{PreStopDelay: time.Minute, Timeout: 50 * time.Millisecond},
+		shutdownStep{name: "http_server", fn: func(ctx context.Context) error {
+			stepErr = ctx.Err()
+			return nil
+		}},
+	)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected drain to respect its budget, took %v", elapsed)
+	}
+	if !errors.Is(stepErr, context.DeadlineExceeded) {
+		t.Errorf("expected steps to see the exhausted budget, got %v", stepErr)
+	}
+}
+
+func TestDrain_ReservesBudgetForLaterSteps(t *testing.T) {
+	server := NewServer()
+
+	var flushErr error
+	var flushBudget time.Duration
+	err := server.drain(ShutdownConfig{Timeout: 300 * time.Millisecond},
+		shutdownStep{name: "http_server", fn: func(ctx context.Context) error {
+			<-ctx.Done() // a server that never finishes stopping
+			return ctx.Err()
+		}},
+		shutdownStep{name: "telemetry", reserve: 100 * time.Millisecond, fn: func(ctx context.Context) error {
+			flushErr = ctx.Err()
+			deadline, _ := ctx.Deadline()
+			flushBudget = time.Until(deadline)
+			return nil
+		}},
+	)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the stuck step to fail, got %v", err)
+	}
+	if flushErr != nil {
+		t.Errorf("expected the reserved step to start with budget left, got %v", flushErr)
+	}
+	if flushBudget < 50*time.Millisecond {
+		t.Errorf("expected about 100ms left for the reserved step, got %v", flushBudget)
+	}
+}
+
+func TestInFlightMiddleware(t *testing.T) {
+	server := NewServer()
+
+	var during int64
+	handler := server.inFlightMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = server.inFlight.Load()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if during != 1 {
+		t.Errorf("expected 1 in-flight request while serving, got %d", during)
+	}
+	if n := server.inFlight.Load(); n != 0 {
+		t.Errorf("expected 0 in-flight requests after serving, got %d", n)
+	}
+}
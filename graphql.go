@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// graphQLListCost is the number of elements assumed for every list field when
+// estimating query complexity.
+const graphQLListCost = 10
+
+// graphQLMaxCost caps measured complexity so pathological documents cannot
+// overflow the estimate; it is far above any sensible max_complexity.
+const graphQLMaxCost = 1 << 30
+
+// graphQLRestaurant and graphQLCategory are derived from menu items; neither
+// has an identity of its own beyond its name.
+type graphQLRestaurant struct {
+	Name string `json:"name"`
+}
+
+type graphQLCategory struct {
+	Name string `json:"name"`
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// tracedResolver runs resolve inside a span named after the field, so each
+// non-trivial resolver shows up in the request's trace.
+func tracedResolver(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		name := p.Info.ParentType.Name() + "." + p.Info.FieldName
+		ctx, span := tracer.Start(p.Context, "graphql.resolve "+name)
+		defer span.End()
+
+		path := make([]string, 0, 4)
+		for _, key := range p.Info.Path.AsArray() {
+			path = append(path, fmt.Sprint(key))
+		}
+		span.SetAttributes(
+			attribute.String("graphql.field.name", p.Info.FieldName),
+			attribute.String("graphql.field.parent_type", p.Info.ParentType.Name()),
+			attribute.String("graphql.field.path", strings.Join(path, ".")),
+		)
+
+		p.Context = ctx
+		result, err := resolve(p)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return result, err
+	}
+}
+
+// filterMenuItems keeps the items matching the optional restaurant, category
+// and available arguments.
+func filterMenuItems(items []MenuItem, args map[string]any) []MenuItem {
+	return slices.DeleteFunc(slices.Clone(items), func(item MenuItem) bool {
+		if r, ok := args["restaurant"].(string); ok && item.Restaurant != r {
+			return true
+		}
+		if c, ok := args["category"].(string); ok && item.Category != c {
+			return true
+		}
+		if a, ok := args["available"].(bool); ok && item.Available != a {
+			return true
+		}
+		return false
+	})
+}
+
+// distinct returns the sorted unique values of key over items.
+func distinct(items []MenuItem, key func(MenuItem) string) []string {
+	var values []string
+	for _, item := range items {
+		if v := key(item); !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	slices.Sort(values)
+	return values
+}
+
+// newGraphQLSchema builds the schema over the Server's menu store. Restaurants
+// and categories are views over menu items.
+func newGraphQLSchema(s *Server) (graphql.Schema, error) {
+	listItems := func(ctx context.Context, args map[string]any) ([]MenuItem, error) {
+		items, err := s.menu.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return filterMenuItems(items, args), nil
+	}
+	restaurantsOf := func(items []MenuItem) []graphQLRestaurant {
+		out := []graphQLRestaurant{}
+		for _, name := range distinct(items, func(i MenuItem) string { return i.Restaurant }) {
+			out = append(out, graphQLRestaurant{Name: name})
+		}
+		return out
+	}
+	categoriesOf := func(items []MenuItem) []graphQLCategory {
+		out := []graphQLCategory{}
+		for _, name := range distinct(items, func(i MenuItem) string { return i.Category }) {
+			out = append(out, graphQLCategory{Name: name})
+		}
+		return out
+	}
+
+	availableArg := graphql.FieldConfigArgument{
+		"available": &graphql.ArgumentConfig{Type: graphql.Boolean, Description: "Only items with this availability."},
+	}
+
+	restaurantType := graphql.NewObject(graphql.ObjectConfig{Name: "Restaurant", Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	}})
+	categoryType := graphql.NewObject(graphql.ObjectConfig{Name: "Category", Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	}})
+	menuItemType := graphql.NewObject(graphql.ObjectConfig{Name: "MenuItem", Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"price":       &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"available":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"prepTimeMinutes": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(MenuItem).PrepTime, nil },
+		},
+		"restaurant": &graphql.Field{
+			Type: graphql.NewNonNull(restaurantType),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return graphQLRestaurant{Name: p.Source.(MenuItem).Restaurant}, nil
+			},
+		},
+		"category": &graphql.Field{
+			Type: graphql.NewNonNull(categoryType),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return graphQLCategory{Name: p.Source.(MenuItem).Category}, nil
+			},
+		},
+	}})
+	menuItemList := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(menuItemType)))
+
+	restaurantType.AddFieldConfig("categories", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+		Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+			items, err := listItems(p.Context, map[string]any{"restaurant": p.Source.(graphQLRestaurant).Name})
+			return categoriesOf(items), err
+		}),
+	})
+	restaurantType.AddFieldConfig("menuItems", &graphql.Field{
+		Type: menuItemList,
+		Args: graphql.FieldConfigArgument{
+			"available": availableArg["available"],
+			"category":  &graphql.ArgumentConfig{Type: graphql.String},
+		},
+		Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+			p.Args["restaurant"] = p.Source.(graphQLRestaurant).Name
+			return listItems(p.Context, p.Args)
+		}),
+	})
+	categoryType.AddFieldConfig("menuItems", &graphql.Field{
+		Type: menuItemList,
+		Args: graphql.FieldConfigArgument{
+			"available":  availableArg["available"],
+			"restaurant": &graphql.ArgumentConfig{Type: graphql.String},
+		},
+		Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+			p.Args["category"] = p.Source.(graphQLCategory).Name
+			return listItems(p.Context, p.Args)
+		}),
+	})
+	categoryType.AddFieldConfig("restaurants", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(restaurantType))),
+		Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+			items, err := listItems(p.Context, map[string]any{"category": p.Source.(graphQLCategory).Name})
+			return restaurantsOf(items), err
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+		"menuItems": &graphql.Field{
+			Type: menuItemList,
+			Args: graphql.FieldConfigArgument{
+				"restaurant": &graphql.ArgumentConfig{Type: graphql.String},
+				"category":   &graphql.ArgumentConfig{Type: graphql.String},
+				"available":  availableArg["available"],
+			},
+			Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+				if rand.Float64() < s.faults.Load().MenuErrorRate {
+					return nil, errors.New("failed to fetch menu items from restaurant database")
+				}
+				return listItems(p.Context, p.Args)
+			}),
+		},
+		"menuItem": &graphql.Field{
+			Type: menuItemType,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+				item, err := s.menu.Get(p.Context, p.Args["id"].(string))
+				if errors.Is(err, ErrMenuItemNotFound) {
+					return nil, nil
+				}
+				return item, err
+			}),
+		},
+		"restaurants": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(restaurantType))),
+			Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+				items, err := listItems(p.Context, nil)
+				return restaurantsOf(items), err
+			}),
+		},
+		"restaurant": &graphql.Field{
+			Type: restaurantType,
+			Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+				items, err := listItems(p.Context, map[string]any{"restaurant": p.Args["name"]})
+				if err != nil || len(items) == 0 {
+					return nil, err
+				}
+				return graphQLRestaurant{Name: items[0].Restaurant}, nil
+			}),
+		},
+		"categories": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+			Resolve: tracedResolver(func(p graphql.ResolveParams) (any, error) {
+				items, err := listItems(p.Context, nil)
+				return categoriesOf(items), err
+			}),
+		},
+	}})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// queryCost is the measured shape of a GraphQL operation.
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+// measureQuery computes the depth and estimated complexity of the operation
+// that would run. Each field costs one plus its children, and children of
+// list fields are counted graphQLListCost times. Introspection fields are
+// free so tooling keeps working under tight limits. Each fragment is measured
+// once and reused wherever it is spread, and complexity saturates at
+// graphQLMaxCost, so documents that fan fragments out exponentially are
+// priced in linear time instead of being expanded.
+func measureQuery(schema graphql.Schema, doc *ast.Document, operationName string) queryCost {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operation == nil || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return queryCost{}
+	}
+
+	// spreads caches each fragment's cost measured from depth zero; callers
+	// shift its depth by their own.
+	spreads := map[string]queryCost{}
+
+	var measure func(set *ast.SelectionSet, parent graphql.Type, depth int, visiting map[string]bool) queryCost
+	measure = func(set *ast.SelectionSet, parent graphql.Type, depth int, visiting map[string]bool) queryCost {
+		var total queryCost
+		add := func(c queryCost) {
+			total.depth = max(total.depth, c.depth)
+			total.complexity = min(total.complexity+c.complexity, graphQLMaxCost)
+		}
+
+		for _, sel := range set.Selections {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if strings.HasPrefix(sel.Name.Value, "__") {
+					continue
+				}
+				fieldType, isList := graphQLFieldType(parent, sel.Name.Value)
+				cost := queryCost{depth: depth + 1, complexity: 1}
+				if sel.SelectionSet != nil {
+					child := measure(sel.SelectionSet, fieldType, depth+1, visiting)
+					cost.depth = max(cost.depth, child.depth)
+					if isList {
+						child.complexity = min(child.complexity, graphQLMaxCost/graphQLListCost) * graphQLListCost
+					}
+					cost.complexity = min(cost.complexity+child.complexity, graphQLMaxCost)
+				}
+				add(cost)
+			case *ast.InlineFragment:
+				typ := parent
+				if sel.TypeCondition != nil {
+					typ = schema.Type(sel.TypeCondition.Name.Value)
+				}
+				add(measure(sel.SelectionSet, typ, depth, visiting))
+			case *ast.FragmentSpread:
+				name := sel.Name.Value
+				fragment, ok := fragments[name]
+				if !ok || visiting[name] {
+					continue
+				}
+				cost, seen := spreads[name]
+				if !seen {
+					visiting[name] = true
+					cost = measure(fragment.SelectionSet, schema.Type(fragment.TypeCondition.Name.Value), 0, visiting)
+					delete(visiting, name)
+					spreads[name] = cost
+				}
+				if cost.depth > 0 {
+					cost.depth += depth
+				}
+				add(cost)
+			}
+		}
+		return total
+	}
+
+	return measure(operation.SelectionSet, schema.QueryType(), 0, map[string]bool{})
+}
+
+// graphQLFieldType returns the named type of parent's field and whether it is
+// a list. Unknown fields yield nil and are left for validation to report.
+func graphQLFieldType(parent graphql.Type, name string) (graphql.Type, bool) {
+	obj, ok := parent.(*graphql.Object)
+	if !ok {
+		return nil, false
+	}
+	field, ok := obj.Fields()[name]
+	if !ok {
+		return nil, false
+	}
+
+	var typ graphql.Type = field.Type
+	isList := false
+	for {
+		switch t := typ.(type) {
+		case *graphql.NonNull:
+			typ = t.OfType
+		case *graphql.List:
+			isList = true
+			typ = t.OfType
+		default:
+			return typ, isList
+		}
+	}
+}
+
+// graphQLHandler serves GraphQL queries over GET (?query=) and POST (JSON
+// body). Operations deeper or more complex than the configured limits are
+// rejected before any resolver runs.
+func (s *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "executeGraphQL")
+	defer span.End()
+
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Request body must be a JSON object with a query")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "GraphQL requests must use GET or POST")
+		return
+	}
+	if req.Query == "" {
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "query is required")
+		return
+	}
+	span.SetAttributes(attribute.String("graphql.operation.name", req.OperationName))
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		_ = writeJSON(w, http.StatusOK, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	limits := s.graphQLLimits.Load()
+	cost := measureQuery(s.graphQLSchema, doc, req.OperationName)
+	span.SetAttributes(
+		attribute.Int("graphql.query.depth", cost.depth),
+		attribute.Int("graphql.query.complexity", cost.complexity),
+	)
+	if limitErr := checkGraphQLLimits(cost, *limits); limitErr != nil {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.SetStatus(codes.Error, limitErr.Message)
+		_ = writeJSON(w, http.StatusOK, &graphql.Result{Errors: []gqlerrors.FormattedError{*limitErr}})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	if err := ctx.Err(); errors.Is(err, context.DeadlineExceeded) {
+		// Resolvers that ran out of time leave a partial result; answer
+		// like the REST routes rather than with a 200.
+		span.RecordError(err)
+		writeTimeout(w, r)
+		return
+	}
+	if result.HasErrors() {
+		span.SetAttributes(attribute.Int("graphql.errors", len(result.Errors)))
+	}
+	_ = writeJSON(w, http.StatusOK, result)
+}
+
+func checkGraphQLLimits(cost queryCost, limits GraphQLConfig) *gqlerrors.FormattedError {
+	switch {
+	case cost.depth > limits.MaxDepth:
+		return &gqlerrors.FormattedError{
+			Message:    fmt.Sprintf("Query depth %d exceeds the limit of %d", cost.depth, limits.MaxDepth),
+			Extensions: map[string]any{"code": "QUERY_TOO_DEEP", "depth": cost.depth, "limit": limits.MaxDepth},
+		}
+	case cost.complexity > limits.MaxComplexity:
+		return &gqlerrors.FormattedError{
+			Message:    fmt.Sprintf("Query complexity %d exceeds the limit of %d", cost.complexity, limits.MaxComplexity),
+			Extensions: map[string]any{"code": "QUERY_TOO_COMPLEX", "complexity": cost.complexity, "limit": limits.MaxComplexity},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type graphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, server *Server, query string, variables map[string]any) graphQLResponse {
+	t.Helper()
+
+	body, _ := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	rec := httptest.NewRecorder()
+	server.graphQLHandler(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp graphQLResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+// =============================================================================
+// /graphql Tests
+// =============================================================================
+
+func TestGraphQL_RestaurantInOneRoundTrip(t *testing.T) {
+	server := newTestServerWithoutFaults()
+
+	resp := postGraphQL(t, server, `query ($name: String!) {
+		restaurant(name: $name) {
+			name
+			categories { name }
+			menuItems(available: true) { id name price prepTimeMinutes category { name } }
+		}
+	}`, map[string]any{"name": "Tony's Pizza"})
+
+	if len(resp.Errors) != 0 {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+	var restaurant struct {
+		Name       string
+		Categories []struct{ Name string }
+		MenuItems  []struct {
+			ID              string
+			Name            string
+			Price           float64
+			PrepTimeMinutes int
+			Category        struct{ Name string }
+		}
+	}
+	if err := json.Unmarshal(resp.Data["restaurant"], &restaurant); err != nil {
+		t.Fatalf("failed to decode restaurant: %v", err)
+	}
+	if restaurant.Name != "Tony's Pizza" || len(restaurant.Categories) != 1 || restaurant.Categories[0].Name != "Pizza" {
+		t.Errorf("unexpected restaurant: %+v", restaurant)
+	}
+	if len(restaurant.MenuItems) != 1 || restaurant.MenuItems[0].PrepTimeMinutes != 20 || restaurant.MenuItems[0].Category.Name != "Pizza" {
+		t.Errorf("unexpected menu items: %+v", restaurant.MenuItems)
+	}
+}
+
+func TestGraphQL_Filters(t *testing.T) {
+	server := newTestServerWithoutFaults()
+
+	testCases := []struct {
+		query    string
+		expected int
+	}{
+		{`{ menuItems { id } }`, 5},
+		{`{ menuItems(available: false) { id } }`, 1},
+		{`{ menuItems(category: "Pizza") { id } }`, 1},
+		{`{ restaurants { name } }`, 5},
+		{`{ categories { name } }`, 5},
+	}
+
+	for _, tc := range testCases {
+		resp := postGraphQL(t, server, tc.query, nil)
+		var list []json.RawMessage
+		for _, v := range resp.Data {
+			_ = json.Unmarshal(v, &list)
+		}
+		if len(list) != tc.expected {
+			t.Errorf("%s: expected %d results, got %d (errors %+v)", tc.query, tc.expected, len(list), resp.Errors)
+		}
+	}
+}
+
+func TestGraphQL_UnknownItemIsNull(t *testing.T) {
+	resp := postGraphQL(t, newTestServerWithoutFaults(), `{ menuItem(id: "999") { name } }`, nil)
+
+	if len(resp.Errors) != 0 || string(resp.Data["menuItem"]) != "null" {
+		t.Errorf("expected null without errors, got data %s errors %+v", resp.Data["menuItem"], resp.Errors)
+	}
+}
+
+func TestGraphQL_Limits(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"too deep", `{ menuItems { restaurant { categories { restaurants { categories { restaurants { name } } } } } } }`, "QUERY_TOO_DEEP"},
+		{"too deep via fragment", `{ menuItems { ...R } } fragment R on MenuItem { restaurant { categories { restaurants { categories { restaurants { name } } } } } }`, "QUERY_TOO_DEEP"},
+		{"too complex", `{ restaurants { categories { menuItems { id name price available description prepTimeMinutes } } } }`, "QUERY_TOO_COMPLEX"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServerWithoutFaults()
+			resp := postGraphQL(t, server, tc.query, nil)
+
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tc.code {
+				t.Fatalf("expected a %s error, got %+v", tc.code, resp.Errors)
+			}
+			if resp.Data != nil {
+				t.Errorf("expected no data for a rejected query, got %v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestGraphQL_IntrospectionIsNotLimited(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.graphQLLimits.Store(&GraphQLConfig{MaxDepth: 2, MaxComplexity: 5})
+
+	resp := postGraphQL(t, server, `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`, nil)
+
+	if len(resp.Errors) != 0 {
+		t.Errorf("expected introspection to be allowed, got %+v", resp.Errors)
+	}
+}
+
+func TestMeasureQuery(t *testing.T) {
+	server := newTestServerWithoutFaults()
+
+	testCases := []struct {
+		query      string
+		depth      int
+		complexity int
+	}{
+		{`{ menuItem(id: "1") { name } }`, 2, 2},
+		{`{ menuItems { name price } }`, 2, 21},
+		{`{ restaurant(name: "x") { categories { name } } }`, 3, 12},
+		{`query A { menuItems { id } } query B { categories { name } }`, 2, 11},
+	}
+
+	for _, tc := range testCases {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(tc.query)+"&operationName=A", nil)
+		server.graphQLLimits.Store(&GraphQLConfig{MaxDepth: tc.depth, MaxComplexity: tc.complexity})
+		server.graphQLHandler(resp, req)
+		if strings.Contains(resp.Body.String(), "QUERY_TOO") {
+			t.Errorf("%s: expected to fit depth %d complexity %d, got %s", tc.query, tc.depth, tc.complexity, resp.Body.String())
+		}
+
+		server.graphQLLimits.Store(&GraphQLConfig{MaxDepth: tc.depth, MaxComplexity: tc.complexity - 1})
+		resp = httptest.NewRecorder()
+		server.graphQLHandler(resp, req)
+		if !strings.Contains(resp.Body.String(), "QUERY_TOO_COMPLEX") {
+			t.Errorf("%s: expected complexity above %d, got %s", tc.query, tc.complexity-1, resp.Body.String())
+		}
+	}
+}
+
+// A chain of fragments that each spread the next twice describes 2^26
+// selections; it must be priced without expanding it.
+func TestMeasureQuery_FragmentFanOut(t *testing.T) {
+	server := newTestServerWithoutFaults()
+
+	const links = 27
+	var doc strings.Builder
+	doc.WriteString("query { ...F0 }\n")
+	for i := 0; i < links-1; i++ {
+		fmt.Fprintf(&doc, "fragment F%d on Query { ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	fmt.Fprintf(&doc, "fragment F%d on Query { menuItems { id name } }\n", links-1)
+
+	start := time.Now()
+	resp := postGraphQL(t, server, doc.String(), nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the document to be priced quickly, took %v", elapsed)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "QUERY_TOO_COMPLEX" {
+		t.Fatalf("expected QUERY_TOO_COMPLEX, got %+v", resp.Errors)
+	}
+	if got := resp.Errors[0].Extensions["complexity"]; got != float64(graphQLMaxCost) {
+		t.Errorf("expected complexity to saturate at %d, got %v", graphQLMaxCost, got)
+	}
+}
+
+func TestGraphQL_ResolverSpans(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	recorder := installSpanRecorder()
+
+	ctx, root := tracer.Start(context.Background(), "request")
+	body, _ := json.Marshal(graphQLRequest{Query: `{ restaurants { name categories { name } } }`})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)).WithContext(ctx)
+	server.graphQLHandler(httptest.NewRecorder(), req)
+	root.End()
+
+	resolvers := map[string]int{}
+	for _, span := range spansInTrace(recorder, root.SpanContext().TraceID()) {
+		if strings.HasPrefix(span.Name(), "graphql.resolve ") {
+			resolvers[span.Name()]++
+		}
+	}
+	if resolvers["graphql.resolve Query.restaurants"] != 1 {
+		t.Errorf("expected one Query.restaurants span, got %v", resolvers)
+	}
+	if resolvers["graphql.resolve Restaurant.categories"] != 5 {
+		t.Errorf("expected a Restaurant.categories span per restaurant, got %v", resolvers)
+	}
+}
+
+func TestGraphQL_BadRequests(t *testing.T) {
+	server := NewServer()
+
+	testCases := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/graphql", "not json", http.StatusBadRequest},
+		{http.MethodPost, "/graphql", `{"query": ""}`, http.StatusBadRequest},
+		{http.MethodGet, "/graphql?query=%7B%7D&variables=nope", "", http.StatusBadRequest},
+		{http.MethodDelete, "/graphql", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		server.graphQLHandler(rec, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Errorf("%s %s %q: expected status %d, got %d", tc.method, tc.target, tc.body, tc.status, rec.Code)
+		}
+	}
+}
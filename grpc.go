@@ -0,0 +1,304 @@
+package main
+
+//go:generate protoc -I proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative menu/v1/menu.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	menuv1 "github.com/blackswan/mock-go/proto/menu/v1"
+)
+
+var menuEventTypes = map[MenuEventType]menuv1.MenuEvent_Type{
+	MenuItemCreated:             menuv1.MenuEvent_TYPE_CREATED,
+	MenuItemUpdated:             menuv1.MenuEvent_TYPE_UPDATED,
+	MenuItemDeleted:             menuv1.MenuEvent_TYPE_DELETED,
+	MenuItemAvailabilityChanged: menuv1.MenuEvent_TYPE_AVAILABILITY,
+}
+
+// menuGRPCService implements menuv1.MenuServiceServer over the Server's store.
+type menuGRPCService struct {
+	menuv1.UnimplementedMenuServiceServer
+	server *Server
+}
+
+// grpcServer bundles the gRPC server with its health service so drain can
+// report NOT_SERVING before stopping.
+type grpcServer struct {
+	*grpc.Server
+	health *health.Server
+}
+
+// newGRPCServer builds the gRPC server exposing MenuService, the standard
+// health service and reflection. tlsConfig may be nil for plaintext.
+func newGRPCServer(s *Server, tlsConfig *tls.Config) *grpcServer {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.grpcInFlightUnary, grpcLoggingUnary, s.grpcAuthUnary),
+		grpc.ChainStreamInterceptor(s.grpcInFlightStream, grpcLoggingStream, s.grpcAuthStream),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	srv := &grpcServer{Server: grpc.NewServer(opts...), health: health.NewServer()}
+	menuv1.RegisterMenuServiceServer(srv, &menuGRPCService{server: s})
+	healthpb.RegisterHealthServer(srv, srv.health)
+	reflection.Register(srv)
+	return srv
+}
+
+// shutdown marks the server NOT_SERVING and waits for in-flight RPCs, closing
+// remaining streams once ctx is done.
+func (g *grpcServer) shutdown(ctx context.Context) error {
+	g.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		g.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		g.Stop()
+		return ctx.Err()
+	}
+}
+
+func (g *menuGRPCService) ListMenuItems(ctx context.Context, _ *menuv1.ListMenuItemsRequest) (*menuv1.ListMenuItemsResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if rand.Float64() < g.server.faults.Load().MenuErrorRate {
+		span.RecordError(errors.New("database connection failed"))
+		return nil, status.Error(codes.Unavailable, "Failed to fetch menu items from restaurant database")
+	}
+
+	items, err := g.server.menu.List(ctx)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "Failed to fetch menu items from restaurant database")
+	}
+
+	span.SetAttributes(attribute.Int("menu.count", len(items)))
+	resp := &menuv1.ListMenuItemsResponse{MenuItems: make([]*menuv1.MenuItem, 0, len(items))}
+	for _, item := range items {
+		resp.MenuItems = append(resp.MenuItems, menuItemToProto(item))
+	}
+	return resp, nil
+}
+
+func (g *menuGRPCService) GetMenuItem(ctx context.Context, req *menuv1.GetMenuItemRequest) (*menuv1.GetMenuItemResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("menu.item.id", req.GetId()))
+
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Menu item ID is required")
+	}
+
+	item, err := g.server.menu.Get(ctx, req.GetId())
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if errors.Is(err, ErrMenuItemNotFound) {
+		return nil, status.Errorf(codes.NotFound, "Menu item with ID '%s' not found", req.GetId())
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Error(codes.Internal, "Failed to fetch menu item from restaurant database")
+	}
+
+	span.SetAttributes(
+		attribute.String("menu.item.name", item.Name),
+		attribute.Float64("menu.item.price", item.Price),
+	)
+	return &menuv1.GetMenuItemResponse{MenuItem: menuItemToProto(item)}, nil
+}
+
+// WatchMenu subscribes before taking the snapshot so no change is missed; a
+// change racing the snapshot may be delivered twice.
+func (g *menuGRPCService) WatchMenu(_ *menuv1.WatchMenuRequest, stream grpc.ServerStreamingServer[menuv1.MenuEvent]) error {
+	ctx := stream.Context()
+
+	events, cancel := g.server.menu.Subscribe()
+	defer cancel()
+
+	items, err := g.server.menu.List(ctx)
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		trace.SpanFromContext(ctx).RecordError(err)
+		return status.Error(codes.Internal, "Failed to fetch menu items from restaurant database")
+	}
+	for _, item := range items {
+		if err := stream.Send(&menuv1.MenuEvent{Type: menuv1.MenuEvent_TYPE_SNAPSHOT, Item: menuItemToProto(item)}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.server.closing:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, reconnect to resynchronise")
+			}
+			if err := stream.Send(menuEventToProto(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func menuItemToProto(item MenuItem) *menuv1.MenuItem {
+	return &menuv1.MenuItem{
+		Id:              item.ID,
+		Name:            item.Name,
+		Price:           item.Price,
+		Available:       item.Available,
+		Description:     item.Description,
+		Restaurant:      item.Restaurant,
+		Category:        item.Category,
+		PrepTimeMinutes: int32(item.PrepTime),
+	}
+}
+
+func menuEventToProto(event MenuEvent) *menuv1.MenuEvent {
+	return &menuv1.MenuEvent{
+		Id:   event.ID,
+		Type: menuEventTypes[event.Type],
+		Item: menuItemToProto(event.Item),
+		Time: timestamppb.New(event.Time),
+	}
+}
+
+func (s *Server) grpcInFlightUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	return handler(ctx, req)
+}
+
+// grpcInFlightStream counts streams such as WatchMenu for as long as they
+// are open, like SSE and tablet connections on the HTTP side.
+func (s *Server) grpcInFlightStream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	return handler(srv, ss)
+}
+
+// grpcAPIKeyMetadata carries the API key of gRPC calls, like X-API-Key does
+// for HTTP; bearer tokens go in authorization metadata as they do in HTTP.
+const (
+	grpcAPIKeyMetadata        = "x-api-key"
+	grpcAuthorizationMetadata = "authorization"
+)
+
+// grpcScope returns the scope a gRPC method needs: menu:read for the menu
+// service, and none for health checks and reflection.
+func grpcScope(fullMethod string) scope {
+	if strings.HasPrefix(fullMethod, "/"+menuv1.MenuService_ServiceDesc.ServiceName+"/") {
+		return scopeMenuRead
+	}
+	return ""
+}
+
+// grpcAuthorize checks the API key or bearer token of a call to method once
+// authentication is configured, returning ctx with the caller's principal.
+func (s *Server) grpcAuthorize(ctx context.Context, method string) (context.Context, error) {
+	required := grpcScope(method)
+	if required == "" || !s.auth.enabled() {
+		return ctx, nil
+	}
+
+	var creds authCredentials
+	if values := metadata.ValueFromIncomingContext(ctx, grpcAPIKeyMetadata); len(values) > 0 {
+		creds.apiKey = values[0]
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, grpcAuthorizationMetadata); len(values) > 0 {
+		creds.bearer = bearerToken(values[0])
+	}
+	p, failure := s.auth.authorize(ctx, creds, required)
+	s.auth.record(ctx, p, failure, attribute.String("rpc.method", method))
+	switch failure {
+	case "":
+		return context.WithValue(ctx, principalKey{}, p), nil
+	case authFailureForbidden:
+		return nil, status.Errorf(codes.PermissionDenied, "Credentials lack the %s scope", required)
+	}
+	return nil, status.Error(codes.Unauthenticated, "A valid API key in "+grpcAPIKeyMetadata+" or bearer token in "+grpcAuthorizationMetadata+" metadata is required")
+}
+
+func (s *Server) grpcAuthUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.grpcAuthorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcAuthStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := s.grpcAuthorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func grpcLoggingUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logGRPCCall(info.FullMethod, start, err)
+	return resp, err
+}
+
+func grpcLoggingStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logGRPCCall(info.FullMethod, start, err)
+	return err
+}
+
+func logGRPCCall(method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	logger := log.Info()
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+		logger = log.Error()
+	default:
+		logger = log.Warn()
+	}
+
+	logger.
+		Str("method", method).
+		Str("code", code.String()).
+		Dur("duration", time.Since(start)).
+		Msg("gRPC request")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	menuv1 "github.com/blackswan/mock-go/proto/menu/v1"
+)
+
+// dialGRPC serves newGRPCServer over an in-memory listener and returns a
+// connected client.
+func dialGRPC(t *testing.T, server *Server) (*grpcServer, *grpc.ClientConn) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(server, nil)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return srv, conn
+}
+
+func newTestServerWithoutFaults() *Server {
+	server := NewServer()
+	server.faults.Store(&FaultConfig{})
+	return server
+}
+
+// =============================================================================
+// MenuService Tests
+// =============================================================================
+
+func TestGRPC_ListMenuItems(t *testing.T) {
+	_, conn := dialGRPC(t, newTestServerWithoutFaults())
+	client := menuv1.NewMenuServiceClient(conn)
+
+	resp, err := client.ListMenuItems(context.Background(), &menuv1.ListMenuItemsRequest{})
+	if err != nil {
+		t.Fatalf("ListMenuItems returned error: %v", err)
+	}
+	if len(resp.GetMenuItems()) != 5 {
+		t.Fatalf("expected 5 menu items, got %d", len(resp.GetMenuItems()))
+	}
+	if item := resp.GetMenuItems()[0]; item.GetName() != "Margherita Pizza" || item.GetPrepTimeMinutes() != 20 {
+		t.Errorf("unexpected first item: %v", item)
+	}
+}
+
+func TestGRPC_ListMenuItemsFault(t *testing.T) {
+	server := NewServer()
+	server.faults.Store(&FaultConfig{MenuErrorRate: 1})
+	_, conn := dialGRPC(t, server)
+
+	_, err := menuv1.NewMenuServiceClient(conn).ListMenuItems(context.Background(), &menuv1.ListMenuItemsRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestGRPC_GetMenuItem(t *testing.T) {
+	_, conn := dialGRPC(t, newTestServerWithoutFaults())
+	client := menuv1.NewMenuServiceClient(conn)
+
+	testCases := []struct {
+		id   string
+		code codes.Code
+	}{
+		{"2", codes.OK},
+		{"999", codes.NotFound},
+		{"", codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		resp, err := client.GetMenuItem(context.Background(), &menuv1.GetMenuItemRequest{Id: tc.id})
+		if status.Code(err) != tc.code {
+			t.Errorf("id %q: expected %s, got %v", tc.id, tc.code, err)
+		}
+		if tc.code == codes.OK && resp.GetMenuItem().GetName() != "Chicken Pad Thai" {
+			t.Errorf("id %q: unexpected item %v", tc.id, resp.GetMenuItem())
+		}
+	}
+}
+
+func TestGRPC_WatchMenu(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	_, conn := dialGRPC(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := menuv1.NewMenuServiceClient(conn).WatchMenu(ctx, &menuv1.WatchMenuRequest{})
+	if err != nil {
+		t.Fatalf("WatchMenu returned error: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("failed to receive snapshot: %v", err)
+		}
+		if event.GetType() != menuv1.MenuEvent_TYPE_SNAPSHOT {
+			t.Fatalf("expected snapshot event, got %v", event.GetType())
+		}
+	}
+
+	item, _ := server.menu.Get(ctx, "3")
+	item.Available = true
+	if err := server.menu.Put(ctx, item); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive change: %v", err)
+	}
+	if event.GetType() != menuv1.MenuEvent_TYPE_AVAILABILITY || event.GetItem().GetId() != "3" || !event.GetItem().GetAvailable() {
+		t.Errorf("unexpected change event: %v", event)
+	}
+}
+
+func TestGRPC_WatchMenuCountedInFlight(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	_, conn := dialGRPC(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := menuv1.NewMenuServiceClient(conn).WatchMenu(ctx, &menuv1.WatchMenuRequest{})
+	if err != nil {
+		t.Fatalf("WatchMenu returned error: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("failed to receive snapshot: %v", err)
+	}
+	if n := server.inFlight.Load(); n != 1 {
+		t.Errorf("expected the open stream to be in flight, got %d", n)
+	}
+
+	cancel()
+	eventually(t, func() bool { return server.inFlight.Load() == 0 })
+}
+
+// stallingListStore never answers List, giving up only when the caller's
+// context ends.
+type stallingListStore struct {
+	MenuStore
+}
+
+func (s stallingListStore) List(ctx context.Context) ([]MenuItem, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// watchStream is a WatchMenu stream that only has a context, for calling the
+// service directly.
+type watchStream struct {
+	grpc.ServerStreamingServer[menuv1.MenuEvent]
+	ctx context.Context
+}
+
+func (s watchStream) Context() context.Context { return s.ctx }
+
+func TestGRPC_WatchMenuSnapshotHonoursContext(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.menu = stallingListStore{MenuStore: server.menu}
+	service := &menuGRPCService{server: server}
+
+	expired, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := service.WatchMenu(&menuv1.WatchMenuRequest{}, watchStream{ctx: expired}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := service.WatchMenu(&menuv1.WatchMenuRequest{}, watchStream{ctx: canceled}); status.Code(err) != codes.Canceled {
+		t.Errorf("expected Canceled, got %v", err)
+	}
+}
+
+func TestGRPC_HealthNotServingAfterShutdown(t *testing.T) {
+	srv, conn := dialGRPC(t, newTestServerWithoutFaults())
+	client := healthpb.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected SERVING, got %v (err %v)", resp.GetStatus(), err)
+	}
+
+	if err := srv.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown returned error: %v", err)
+	}
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err == nil {
+		t.Error("expected checks to fail after shutdown")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// probe identifies which Kubernetes probe(s) a health check contributes to.
+type probe uint8
+
+const (
+	probeLiveness probe = 1 << iota
+	probeReadiness
+	probeStartup
+)
+
+type healthCheck func(ctx context.Context) error
+
+type registeredCheck struct {
+	name     string
+	probes   probe
+	check    healthCheck
+	optional bool
+}
+
+type checkResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+}
+
+// ProbeReport is the body of the /livez, /readyz and /startupz probes.
+type ProbeReport struct {
+	Status    string        `json:"status"`
+	Checks    []checkResult `json:"checks"`
+	Timestamp string        `json:"timestamp"`
+}
+
+// healthRegistry holds the named dependency checks behind /livez, /readyz and
+// /startupz. Checks run concurrently on every probe request.
+type healthRegistry struct {
+	mu     sync.RWMutex
+	checks []registeredCheck
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{}
+}
+
+// Register adds a check to the given probes, replacing any check already
+// registered under the same name.
+func (h *healthRegistry) Register(name string, probes probe, check healthCheck) {
+	h.register(registeredCheck{name: name, probes: probes, check: check})
+}
+
+// RegisterOptional adds a check whose failure is reported as degraded without
+// failing the probes, for dependencies the API can serve without, such as the
+// telemetry exporter.
+func (h *healthRegistry) RegisterOptional(name string, probes probe, check healthCheck) {
+	h.register(registeredCheck{name: name, probes: probes, check: check, optional: true})
+}
+
+func (h *healthRegistry) register(c registeredCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = slices.DeleteFunc(h.checks, func(existing registeredCheck) bool { return existing.name == c.name })
+	h.checks = append(h.checks, c)
+}
+
+func (h *healthRegistry) run(ctx context.Context, p probe) (bool, []checkResult) {
+	h.mu.RLock()
+	var checks []registeredCheck
+	for _, c := range h.checks {
+		if c.probes&p != 0 {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	results := make([]checkResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.check(ctx)
+			results[i] = checkResult{
+				Name:       c.name,
+				Status:     "ok",
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = "failed"
+				if c.optional {
+					results[i].Status = "degraded"
+				}
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, r := range results {
+		if r.Status == "failed" {
+			healthy = false
+		}
+	}
+	slices.SortFunc(results, func(a, b checkResult) int { return cmp.Compare(a.Name, b.Name) })
+	return healthy, results
+}
+
+// handler serves a probe endpoint, answering 503 when a check fails and 200
+// with status "degraded" when only optional checks do. Every check's status
+// is always listed; ?verbose additionally includes error messages and
+// timings, which may expose dependency addresses and are therefore opt-in.
+func (h *healthRegistry) handler(p probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		healthy, results := h.run(r.Context(), p)
+
+		query := r.URL.Query()
+		verbose := query.Has("verbose") && query.Get("verbose") != "false" && query.Get("verbose") != "0"
+		if !verbose {
+			for i := range results {
+				results[i].Error = ""
+				results[i].DurationMS = 0
+			}
+		}
+
+		status, code := "ok", http.StatusOK
+		if slices.ContainsFunc(results, func(r checkResult) bool { return r.Status == "degraded" }) {
+			status = "degraded"
+		}
+		if !healthy {
+			status, code = "failed", http.StatusServiceUnavailable
+		}
+
+		_ = writeJSON(w, code, ProbeReport{
+			Status:    status,
+			Checks:    results,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+// grpcConnCheck reports a gRPC client connection as unhealthy only once it
+// has actually failed; idle and connecting states are normal between exports.
+func grpcConnCheck(conn *grpc.ClientConn) healthCheck {
+	return func(context.Context) error {
+		switch state := conn.GetState(); state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return errors.New("connection " + state.String())
+		case connectivity.Idle:
+			conn.Connect()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type probeResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+func getProbe(t *testing.T, h http.Handler, target string) (int, probeResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var result probeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal probe response: %v", err)
+	}
+	return rec.Code, result
+}
+
+// =============================================================================
+// healthRegistry Tests
+// =============================================================================
+
+func TestHealthRegistry_AllChecksPass(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("a", probeReadiness, func(context.Context) error { return nil })
+	h.Register("b", probeReadiness, func(context.Context) error { return nil })
+
+	code, result := getProbe(t, h.handler(probeReadiness), "/readyz")
+
+	if code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if result.Status != "ok" {
+		t.Errorf("expected status 'ok', got %q", result.Status)
+	}
+	if len(result.Checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(result.Checks))
+	}
+	if result.Checks[0].Name != "a" || result.Checks[1].Name != "b" {
+		t.Errorf("expected checks sorted by name, got %+v", result.Checks)
+	}
+}
+
+func TestHealthRegistry_OptionalCheckDegrades(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("menu_store", probeReadiness, func(context.Context) error { return nil })
+	h.RegisterOptional("otlp_exporter", probeReadiness, func(context.Context) error { return errors.New("connection TRANSIENT_FAILURE") })
+
+	code, result := getProbe(t, h.handler(probeReadiness), "/readyz?verbose")
+
+	if code != http.StatusOK {
+		t.Errorf("expected an optional failure to keep status %d, got %d", http.StatusOK, code)
+	}
+	if result.Status != "degraded" {
+		t.Errorf("expected status 'degraded', got %q", result.Status)
+	}
+	if c := result.Checks[1]; c.Name != "otlp_exporter" || c.Status != "degraded" || c.Error == "" {
+		t.Errorf("expected otlp_exporter to be degraded with its error, got %+v", c)
+	}
+
+	h.Register("menu_store", probeReadiness, func(context.Context) error { return errors.New("down") })
+	if code, result := getProbe(t, h.handler(probeReadiness), "/readyz"); code != http.StatusServiceUnavailable || result.Status != "failed" {
+		t.Errorf("expected a required failure to fail the probe, got %d %q", code, result.Status)
+	}
+}
+
+func TestHealthRegistry_FailingCheck(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("ok", probeReadiness, func(context.Context) error { return nil })
+	h.Register("broken", probeReadiness, func(context.Context) error { return errors.New("boom") })
+
+	code, result := getProbe(t, h.handler(probeReadiness), "/readyz")
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if result.Status != "failed" {
+		t.Errorf("expected status 'failed', got %q", result.Status)
+	}
+
+	for _, c := range result.Checks {
+		if c.Error != "" {
+			t.Errorf("expected error detail to be hidden without verbose, got %q", c.Error)
+		}
+		if c.Name == "broken" && c.Status != "failed" {
+			t.Errorf("expected 'broken' to be failed, got %q", c.Status)
+		}
+	}
+}
+
+func TestHealthRegistry_Verbose(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("broken", probeReadiness, func(context.Context) error { return errors.New("boom") })
+
+	for _, target := range []string{"/readyz?verbose", "/readyz?verbose=true", "/readyz?verbose=1"} {
+		t.Run(target, func(t *testing.T) {
+			_, result := getProbe(t, h.handler(probeReadiness), target)
+
+			if len(result.Checks) != 1 || result.Checks[0].Error != "boom" {
+				t.Errorf("expected error detail 'boom', got %+v", result.Checks)
+			}
+		})
+	}
+}
+
+func TestHealthRegistry_ProbeSelection(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("readiness_only", probeReadiness, func(context.Context) error { return errors.New("not ready") })
+	h.Register("shared", probeLiveness|probeStartup, func(context.Context) error { return nil })
+
+	testCases := []struct {
+		name   string
+		probe  probe
+		code   int
+		checks int
+	}{
+		{"Liveness", probeLiveness, http.StatusOK, 1},
+		{"Readiness", probeReadiness, http.StatusServiceUnavailable, 1},
+		{"Startup", probeStartup, http.StatusOK, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, result := getProbe(t, h.handler(tc.probe), "/")
+
+			if code != tc.code {
+				t.Errorf("expected status %d, got %d", tc.code, code)
+			}
+			if len(result.Checks) != tc.checks {
+				t.Errorf("expected %d checks, got %d", tc.checks, len(result.Checks))
+			}
+		})
+	}
+}
+
+func TestHealthRegistry_RegisterReplaces(t *testing.T) {
+	h := newHealthRegistry()
+	h.Register("dep", probeReadiness, func(context.Context) error { return errors.New("old") })
+	h.Register("dep", probeReadiness, func(context.Context) error { return nil })
+
+	healthy, results := h.run(context.Background(), probeReadiness)
+
+	if !healthy {
+		t.Error("expected replaced check to pass")
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 check after replacement, got %d", len(results))
+	}
+}
+
+// =============================================================================
+// Built-in Check Tests
+// =============================================================================
+
+func TestServer_ReadinessFailsWhileDraining(t *testing.T) {
+	server := NewServer()
+	handler := newHTTPHandler(server)
+
+	if code, _ := getProbe(t, handler, "/readyz"); code != http.StatusOK {
+		t.Fatalf("expected ready server, got status %d", code)
+	}
+
+	server.draining.Store(true)
+
+	if code, _ := getProbe(t, handler, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d while draining, got %d", http.StatusServiceUnavailable, code)
+	}
+	if code, _ := getProbe(t, handler, "/livez"); code != http.StatusOK {
+		t.Errorf("expected liveness to stay %d while draining, got %d", http.StatusOK, code)
+	}
+}
+
+func TestServer_StartupFailsWithoutMenu(t *testing.T) {
+	server := NewServer()
+	server.menu = newMemoryMenuStore(nil)
+
+	code, result := getProbe(t, newHTTPHandler(server), "/startupz?verbose")
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if len(result.Checks) != 1 || result.Checks[0].Name != "menu_store" {
+		t.Errorf("expected only the menu_store check, got %+v", result.Checks)
+	}
+}
+
+func TestGRPCConnCheck(t *testing.T) {
+	conn, err := grpc.NewClient("localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	check := grpcConnCheck(conn)
+
+	if err := check(context.Background()); err != nil {
+		t.Errorf("expected idle connection to pass, got %v", err)
+	}
+
+	_ = conn.Close()
+
+	if err := check(context.Background()); err == nil {
+		t.Error("expected closed connection to fail")
+	}
+}
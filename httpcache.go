@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encodedResponse is a JSON body with the validators clients revalidate it
+// with.
+type encodedResponse struct {
+	body     []byte
+	etag     string
+	modified time.Time
+}
+
+// responseCache keeps the encoded bodies of menu responses for the current
+// menu revision, so repeated GETs neither reread the store nor re-serialize.
+// A write moves the store to a new revision, which drops every entry.
+type responseCache struct {
+	mu       sync.Mutex
+	revision uint64
+	entries  map[string]encodedResponse
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{entries: map[string]encodedResponse{}}
+}
+
+// get returns the response cached under key for rev, building and caching it
+// with build on a miss.
+func (c *responseCache) get(key string, rev MenuRevision, build func() (any, error)) (encodedResponse, bool, error) {
+	c.mu.Lock()
+	if c.revision != rev.ID {
+		c.revision = rev.ID
+		clear(c.entries)
+	}
+	resp, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return resp, true, nil
+	}
+
+	v, err := build()
+	if err != nil {
+		return encodedResponse{}, false, err
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return encodedResponse{}, false, err
+	}
+	sum := sha256.Sum256(body)
+	// Weak, because compressed and identity encodings share the tag.
+	resp = encodedResponse{
+		body:     body,
+		etag:     `W/"` + hex.EncodeToString(sum[:12]) + `"`,
+		modified: rev.Modified,
+	}
+
+	c.mu.Lock()
+	if c.revision == rev.ID {
+		c.entries[key] = resp
+	}
+	c.mu.Unlock()
+	return resp, false, nil
+}
+
+// cacheControl renders cfg as a Cache-Control value for shared caches, or
+// for the client's own cache only when the response is private.
+func cacheControl(cfg HTTPCacheConfig, private bool) string {
+	switch {
+	case private && cfg.MaxAge <= 0, cfg.MaxAge <= 0 && cfg.SharedMaxAge <= 0:
+		return "no-cache"
+	case private:
+		return fmt.Sprintf("private, max-age=%d", int(cfg.MaxAge.Seconds()))
+	}
+	value := fmt.Sprintf("public, max-age=%d, s-maxage=%d", int(cfg.MaxAge.Seconds()), int(cfg.SharedMaxAge.Seconds()))
+	if cfg.StaleWhileRevalidate > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", int(cfg.StaleWhileRevalidate.Seconds()))
+	}
+	return value
+}
+
+// writeCacheable writes resp with its validators and Cache-Control, or a 304
+// when the request's conditional headers show the client already has it.
+// Responses to authenticated callers are private, so that a shared cache
+// never hands them to a caller without credentials, and whenever the route's
+// scope was checked the response varies with the credentials presented.
+func (s *Server) writeCacheable(w http.ResponseWriter, r *http.Request, resp encodedResponse) {
+	p := principalFrom(r.Context())
+	h := w.Header()
+	h.Set("ETag", resp.etag)
+	h.Set("Last-Modified", resp.modified.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", cacheControl(*s.httpCache.Load(), p != nil && p.method != authMethodAnonymous))
+	if p != nil {
+		addVary(h, "Authorization")
+		addVary(h, apiKeyHeader)
+	}
+
+	if notModified(r, resp.etag, resp.modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(resp.body)
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no
+// If-None-Match, as RFC 9110 section 13.2.2 orders them.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveConditional(handler http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHTTPCache_ETagAndIfNoneMatch(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	for _, url := range []string{"/api/menu", "/api/menu/1", "/api/v2/menu/1"} {
+		first := serveConditional(handler, url, nil)
+		if first.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", url, first.Code)
+		}
+		etag := first.Header().Get("ETag")
+		if !strings.HasPrefix(etag, `W/"`) {
+			t.Fatalf("%s: expected weak ETag, got %q", url, etag)
+		}
+		if first.Header().Get("Last-Modified") == "" {
+			t.Errorf("%s: missing Last-Modified", url)
+		}
+
+		again := serveConditional(handler, url, http.Header{"If-None-Match": {`"other", ` + etag}})
+		if again.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status 304, got %d", url, again.Code)
+		}
+		if again.Body.Len() != 0 {
+			t.Errorf("%s: 304 must not have a body", url)
+		}
+		if again.Header().Get("ETag") != etag {
+			t.Errorf("%s: 304 should repeat the ETag", url)
+		}
+
+		if rec := serveConditional(handler, url, http.Header{"If-None-Match": {`W/"stale"`}}); rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200 for a stale ETag, got %d", url, rec.Code)
+		}
+	}
+}
+
+func TestHTTPCache_ETagsDifferPerItemAndVersion(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	seen := map[string]string{}
+	for _, url := range []string{"/api/menu", "/api/v2/menu", "/api/menu/1", "/api/menu/2", "/api/v2/menu/1"} {
+		etag := serveConditional(handler, url, nil).Header().Get("ETag")
+		if other, ok := seen[etag]; ok {
+			t.Errorf("%s and %s share ETag %s", url, other, etag)
+		}
+		seen[etag] = url
+	}
+}
+
+func TestHTTPCache_IfModifiedSince(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	lastModified := serveConditional(handler, "/api/menu/1", nil).Header().Get("Last-Modified")
+	if rec := serveConditional(handler, "/api/menu/1", http.Header{"If-Modified-Since": {lastModified}}); rec.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", rec.Code)
+	}
+
+	earlier := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if rec := serveConditional(handler, "/api/menu/1", http.Header{"If-Modified-Since": {earlier}}); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	// If-None-Match takes precedence.
+	rec := serveConditional(handler, "/api/menu/1", http.Header{
+		"If-Modified-Since": {lastModified},
+		"If-None-Match":     {`W/"stale"`},
+	})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 when If-None-Match does not match, got %d", rec.Code)
+	}
+}
+
+func TestHTTPCache_InvalidatedOnWrite(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	handler := newHTTPHandler(server)
+
+	listETag := serveConditional(handler, "/api/menu", nil).Header().Get("ETag")
+	itemETag := serveConditional(handler, "/api/menu/1", nil).Header().Get("ETag")
+
+	item, _ := server.menu.Get(context.Background(), "1")
+	item.Price = 13.49
+	if err := server.menu.Put(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serveConditional(handler, "/api/menu", http.Header{"If-None-Match": {listETag}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == listETag {
+		t.Errorf("expected a fresh listing after a write, got %d with ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+	rec = serveConditional(handler, "/api/menu/1", http.Header{"If-None-Match": {itemETag}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "13.49") {
+		t.Errorf("expected the updated item after a write, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHTTPCache_CacheControl(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	handler := newHTTPHandler(server)
+
+	cc := serveConditional(handler, "/api/menu", nil).Header().Get("Cache-Control")
+	if cc != "public, max-age=10, s-maxage=30, stale-while-revalidate=30" {
+		t.Errorf("unexpected default Cache-Control %q", cc)
+	}
+
+	server.httpCache.Store(&HTTPCacheConfig{})
+	if cc := serveConditional(handler, "/api/menu", nil).Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("expected no-cache when caching is disabled, got %q", cc)
+	}
+}
+
+func TestHTTPCache_PrivateWhenAuthenticated(t *testing.T) {
+	server := newAuthenticatedServer(t, AuthConfig{
+		APIKeys:         map[string]string{"reader": hashedAPIKey("reader-secret", "menu:read")},
+		AnonymousScopes: []string{"menu:read"},
+	})
+	handler := newHTTPHandler(server)
+
+	header := http.Header{}
+	header.Set(apiKeyHeader, "reader-secret")
+	rec := serveConditional(handler, "/api/menu", header)
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, max-age=10" {
+		t.Errorf("expected an authenticated response to be private, got %q", cc)
+	}
+	if vary := strings.Join(rec.Header().Values("Vary"), ", "); !strings.Contains(vary, "Authorization, X-API-Key") {
+		t.Errorf("expected the response to vary with credentials, got %q", vary)
+	}
+
+	rec = serveConditional(handler, "/api/menu", nil)
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, ") {
+		t.Errorf("expected an anonymous read to stay shareable, got %q", cc)
+	}
+	if vary := strings.Join(rec.Header().Values("Vary"), ", "); !strings.Contains(vary, "Authorization, X-API-Key") {
+		t.Errorf("expected the anonymous response to vary with credentials, got %q", vary)
+	}
+}
+
+func TestResponseCache_BuildsOncePerRevision(t *testing.T) {
+	cache := newResponseCache()
+	builds := 0
+	build := func() (any, error) {
+		builds++
+		return map[string]int{"builds": builds}, nil
+	}
+
+	rev := MenuRevision{ID: 1, Modified: time.Now()}
+	first, cached, _ := cache.get("k", rev, build)
+	if cached {
+		t.Error("first get should miss")
+	}
+	second, cached, _ := cache.get("k", rev, build)
+	if !cached || second.etag != first.etag || builds != 1 {
+		t.Errorf("second get should hit, builds=%d", builds)
+	}
+
+	third, cached, _ := cache.get("k", MenuRevision{ID: 2, Modified: time.Now()}, build)
+	if cached || third.etag == first.etag || builds != 2 {
+		t.Errorf("new revision should rebuild, builds=%d", builds)
+	}
+}
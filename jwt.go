@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksMinRefetch is how often a token signed with an unknown key may
+	// trigger a refetch, so rotated keys are picked up before the refresh
+	// interval ends without letting forged key IDs hammer the provider.
+	jwksMinRefetch = 30 * time.Second
+
+	// jwksFetchTimeout bounds a JWKS load.
+	jwksFetchTimeout = 10 * time.Second
+
+	// jwksMaxSize caps the JWKS document read.
+	jwksMaxSize = 1 << 20
+
+	// jwtLeeway absorbs clock skew with the identity provider.
+	jwtLeeway = 30 * time.Second
+)
+
+// jwtAlgorithms are the signature algorithms bearer tokens may use.
+var jwtAlgorithms = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+
+// jwksCache holds the identity provider's signing keys by key ID, read from
+// a file or fetched from a URL. It reloads them every refresh interval, and
+// sooner when a token names a key it does not know. A failed reload keeps the
+// previous keys.
+type jwksCache struct {
+	source  string // file path or URL
+	client  *http.Client
+	refresh time.Duration
+	now     func() time.Time
+	loads   singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]any
+	err       error // of the last load
+	fetched   time.Time
+	attempted time.Time
+}
+
+func newJWKSCache(cfg AuthConfig) *jwksCache {
+	source := cfg.JWKSFile
+	if cfg.JWKSURL != "" {
+		source = cfg.JWKSURL
+	}
+	return &jwksCache{
+		source:  source,
+		client:  &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		refresh: cfg.JWKSRefresh,
+		now:     time.Now,
+	}
+}
+
+// key returns the public key with ID kid. A token without a key ID may use
+// the only key of a single-key set. Callers that need a reload share one,
+// which outlives any caller that gives up waiting for it.
+func (c *jwksCache) key(ctx context.Context, kid string) (any, error) {
+	c.mu.Lock()
+	_, known := c.keys[kid]
+	reload := c.keys == nil || !known || c.now().Sub(c.fetched) >= c.refresh
+	c.mu.Unlock()
+
+	if reload {
+		select {
+		case <-c.loads.DoChan("", func() (any, error) { c.reload(ctx); return nil, nil }):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		return nil, fmt.Errorf("loading JWKS: %w", c.err)
+	}
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// reload loads the keys unless the last attempt was less than jwksMinRefetch
+// ago, also while the provider is unreachable. The load keeps the trace of
+// ctx but not its cancellation, and is bounded by jwksFetchTimeout instead.
+func (c *jwksCache) reload(ctx context.Context) {
+	c.mu.Lock()
+	if c.now().Sub(c.attempted) < jwksMinRefetch {
+		c.mu.Unlock()
+		return
+	}
+	c.attempted = c.now()
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+	keys, err := c.load(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err == nil {
+		c.keys, c.fetched = keys, c.now()
+	}
+}
+
+func (c *jwksCache) load(ctx context.Context) (map[string]any, error) {
+	if !strings.HasPrefix(c.source, "http://") && !strings.HasPrefix(c.source, "https://") {
+		data, err := os.ReadFile(c.source)
+		if err != nil {
+			return nil, err
+		}
+		return parseJWKS(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", c.source, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// jsonWebKey is the subset of RFC 7517 keys the service verifies with.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and P-256 signing keys of a JWKS document by key
+// ID. Keys of other types or uses are skipped; a malformed key fails the
+// whole set, so a broken rotation keeps the previous keys.
+func parseJWKS(data []byte) (map[string]any, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key any
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			if jwk.Crv != "P-256" {
+				continue // not usable with ES256
+			}
+			key, err = jwk.ecdsaKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no RSA or P-256 signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// ecdsaKey returns the P-256 key of k.
+func (k jsonWebKey) ecdsaKey() (*ecdsa.PublicKey, error) {
+	x, errX := base64.RawURLEncoding.DecodeString(k.X)
+	y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid P-256 coordinates")
+	}
+	return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+}
+
+// tokenClaims are the claims read from bearer tokens. Scope holds OAuth 2.0
+// scopes separated by spaces; RestaurantID ties the token to one restaurant,
+// by the slug restaurantID derives from its name.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope        string `json:"scope"`
+	RestaurantID string `json:"restaurant_id"`
+}
+
+// bearerVerifier validates bearer tokens issued by the identity provider.
+type bearerVerifier struct {
+	settings bearerSettings
+	jwks     *jwksCache
+	parser   *jwt.Parser
+}
+
+// bearerSettings are the parts of AuthConfig a bearerVerifier is built from,
+// so reloads that leave them alone keep the cached keys.
+type bearerSettings struct {
+	jwksFile, jwksURL string
+	refresh           time.Duration
+	issuer, audience  string
+}
+
+func bearerSettingsOf(cfg AuthConfig) bearerSettings {
+	return bearerSettings{cfg.JWKSFile, cfg.JWKSURL, cfg.JWKSRefresh, cfg.Issuer, cfg.Audience}
+}
+
+func newBearerVerifier(cfg AuthConfig) *bearerVerifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtAlgorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &bearerVerifier{settings: bearerSettingsOf(cfg), jwks: newJWKSCache(cfg), parser: jwt.NewParser(opts...)}
+}
+
+// verify returns the principal token was issued for. Scopes the service does
+// not know are ignored.
+func (v *bearerVerifier) verify(ctx context.Context, token string) (*principal, error) {
+	var claims tokenClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.jwks.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &principal{id: claims.Subject, method: authMethodJWT, restaurant: claims.RestaurantID}
+	for _, s := range strings.Fields(claims.Scope) {
+		for _, known := range knownScopes {
+			if scope(s) == known {
+				p.scopes = append(p.scopes, known)
+			}
+		}
+	}
+	return p, nil
+}
+
+// validateJWKSSource checks the JWKS settings of cfg without loading them.
+func validateJWKSSource(cfg AuthConfig) error {
+	if cfg.JWKSFile != "" && cfg.JWKSURL != "" {
+		return errors.New("jwks_file and jwks_url are mutually exclusive")
+	}
+	if cfg.JWKSURL != "" {
+		u, err := url.Parse(cfg.JWKSURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("jwks_url: %q is not an http or https URL", cfg.JWKSURL)
+		}
+	}
+	if cfg.JWKSRefresh <= 0 {
+		return fmt.Errorf("jwks_refresh: must be positive, got %s", cfg.JWKSRefresh)
+	}
+	return nil
+}
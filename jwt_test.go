@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	menuv1 "github.com/blackswan/mock-go/proto/menu/v1"
+)
+
+// testIssuer signs tokens the way the identity provider does, with keys
+// generated for the test.
+type testIssuer struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIssuer{rsa: rsaKey, ec: ecKey}
+}
+
+// jwks returns the issuer's public keys as a JWKS document, the RSA key as
+// rsaKID and the EC key as ecKID.
+func (i *testIssuer) jwks(t *testing.T, rsaKID, ecKID string) []byte {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecPoint, err := i.ec.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": rsaKID, "use": "sig", "alg": "RS256", "n": b64(i.rsa.N.Bytes()), "e": b64(big.NewInt(int64(i.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": ecKID, "use": "sig", "alg": "ES256", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sign returns a token for claims signed with the issuer's key for method,
+// naming kid in its header.
+func (i *testIssuer) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.Claims) string {
+	t.Helper()
+	var key any = i.rsa
+	if method == jwt.SigningMethodES256 {
+		key = i.ec
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// claims returns valid claims for the test issuer and audience.
+func testClaims(subject, scopes, restaurant string) tokenClaims {
+	now := time.Now()
+	return tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://id.example.com",
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{"menu-api"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Scope:        scopes,
+		RestaurantID: restaurant,
+	}
+}
+
+// newJWTServer returns a server that accepts the issuer's tokens through a
+// JWKS file.
+func newJWTServer(t *testing.T, issuer *testIssuer) *Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeFile(t, path, issuer.jwks(t, "rsa-1", "ec-1"))
+
+	server := newTestServerWithoutFaults()
+	server.rateLimiter.configure(RateLimitConfig{})
+	server.auth.configureBearer(AuthConfig{
+		JWKSFile:    path,
+		JWKSRefresh: time.Minute,
+		Issuer:      "https://id.example.com",
+		Audience:    "menu-api",
+	})
+	return server
+}
+
+func bearerHeader(token string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	header.Set("Accept", problemContentType)
+	return header
+}
+
+func TestParseJWKS(t *testing.T) {
+	issuer := newTestIssuer(t)
+	keys, err := parseJWKS(issuer.jwks(t, "rsa-1", "ec-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !issuer.rsa.PublicKey.Equal(keys["rsa-1"]) || !issuer.ec.PublicKey.Equal(keys["ec-1"]) {
+		t.Errorf("expected both public keys, got %v", keys)
+	}
+
+	skipped := `{"keys": [
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AAAA"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AAAA", "y": "AAAA"}
+	]}`
+	for name, doc := range map[string]string{
+		"not JSON":        "keys",
+		"no usable keys":  skipped,
+		"bad modulus":     `{"keys": [{"kty": "RSA", "kid": "a", "n": "!", "e": "AQAB"}]}`,
+		"point off curve": `{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "` + strings.Repeat("A", 43) + `", "y": "` + strings.Repeat("A", 43) + `"}]}`,
+	} {
+		if _, err := parseJWKS([]byte(doc)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBearer_ValidatesTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	handler := newHTTPHandler(newJWTServer(t, issuer))
+
+	expired := testClaims("alice", "menu:read", "")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	otherAudience := testClaims("alice", "menu:read", "")
+	otherAudience.Audience = jwt.ClaimStrings{"billing-api"}
+	otherIssuer := testClaims("alice", "menu:read", "")
+	otherIssuer.Issuer = "https://evil.example.com"
+	noExpiry := testClaims("alice", "menu:read", "")
+	noExpiry.ExpiresAt = nil
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("alice", "menu:read", "")).SignedString([]byte("shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := newTestIssuer(t)
+
+	testCases := []struct {
+		name, token string
+		want        int
+	}{
+		{"RS256", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("alice", "menu:read", "")), http.StatusOK},
+		{"ES256", issuer.sign(t, jwt.SigningMethodES256, "ec-1", testClaims("alice", "openid menu:read", "")), http.StatusOK},
+		{"no token", "", http.StatusUnauthorized},
+		{"expired", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", expired), http.StatusUnauthorized},
+		{"no expiry", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", noExpiry), http.StatusUnauthorized},
+		{"other audience", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", otherAudience), http.StatusUnauthorized},
+		{"other issuer", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", otherIssuer), http.StatusUnauthorized},
+		{"HS256", hs256, http.StatusUnauthorized},
+		{"unknown key", stranger.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("alice", "menu:read", "")), http.StatusUnauthorized},
+		{"key of the other algorithm", issuer.sign(t, jwt.SigningMethodES256, "rsa-1", testClaims("alice", "menu:read", "")), http.StatusUnauthorized},
+		{"missing scope", issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("alice", "orders:write", "")), http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{"Accept": {problemContentType}}
+			if tc.token != "" {
+				header = bearerHeader(tc.token)
+			}
+			rec := serveConditional(handler, "/api/menu", header)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			challenge := strings.Join(rec.Header().Values("WWW-Authenticate"), ", ")
+			switch {
+			case tc.want != http.StatusUnauthorized:
+			case tc.token == "" && challenge != "Bearer":
+				t.Errorf("expected a Bearer challenge, got %q", challenge)
+			case tc.token != "" && !strings.Contains(challenge, `error="invalid_token"`):
+				t.Errorf("expected an invalid_token challenge, got %q", challenge)
+			}
+		})
+	}
+}
+
+func TestBearer_RecordsSubjectWithoutToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	handler := loggingMiddleware(newHTTPHandler(newJWTServer(t, issuer)))
+	token := issuer.sign(t, jwt.SigningMethodES256, "ec-1", testClaims("tablet-admin@tonys", "menu:read", "tonys-pizza"))
+
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs)
+
+	serveConditional(handler, "/api/menu", bearerHeader(token))
+
+	var line map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(logs.Bytes()), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["subject"] != "tablet-admin@tonys" || line["restaurant_id"] != "tonys-pizza" {
+		t.Errorf("expected the subject and restaurant to be logged, got %v", line)
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Error("expected the token not to be logged")
+	}
+}
+
+func TestJWKSCache_PicksUpRotatedKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+	var (
+		mu      sync.Mutex
+		doc     = issuer.jwks(t, "2026-01", "ec-1")
+		failing bool
+		fetches atomic.Int32
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(doc)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cache := newJWKSCache(AuthConfig{JWKSURL: ts.URL, JWKSRefresh: 5 * time.Minute})
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := cache.key(ctx, "2026-01"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.key(ctx, "2026-01"); err != nil || fetches.Load() != 1 {
+		t.Fatalf("expected the second lookup to be cached, got %v after %d fetches", err, fetches.Load())
+	}
+
+	// The provider rotates to a new key; the unknown key ID triggers a
+	// refetch once jwksMinRefetch has passed since the last one.
+	mu.Lock()
+	doc = issuer.jwks(t, "2026-02", "ec-1")
+	mu.Unlock()
+	if _, err := cache.key(ctx, "2026-02"); err == nil {
+		t.Error("expected no refetch within jwksMinRefetch of the last one")
+	}
+	now = now.Add(jwksMinRefetch)
+	if _, err := cache.key(ctx, "2026-02"); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %v", err)
+	}
+
+	// An unavailable provider leaves the cached keys in use.
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	now = now.Add(10 * time.Minute)
+	if _, err := cache.key(ctx, "2026-02"); err != nil {
+		t.Errorf("expected the cached key to survive a failed refresh, got %v", err)
+	}
+	if got := fetches.Load(); got != 3 {
+		t.Errorf("expected 3 fetches, got %d", got)
+	}
+}
+
+// A caller that gives up on the first load must not leave the cache empty
+// for jwksMinRefetch, nor keep other callers waiting behind the lock.
+func TestJWKSCache_SurvivesCancelledFirstLoad(t *testing.T) {
+	issuer := newTestIssuer(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Write(issuer.jwks(t, "2026-01", "ec-1"))
+	}))
+	defer ts.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	cache := newJWKSCache(AuthConfig{JWKSURL: ts.URL, JWKSRefresh: 5 * time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := cache.key(ctx, "2026-01"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to give up, got %v", err)
+	}
+
+	close(release)
+	if _, err := cache.key(context.Background(), "2026-01"); err != nil {
+		t.Fatalf("expected the first load to complete for later callers, got %v", err)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("expected a single fetch, got %d", got)
+	}
+}
+
+func TestBearer_RestaurantClaimLimitsEdits(t *testing.T) {
+	issuer := newTestIssuer(t)
+	handler := newHTTPHandler(newJWTServer(t, issuer))
+	tonys := issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("owner@tonys", "menu:read menu:write", "tonys-pizza"))
+	reader := issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("owner@tonys", "menu:read", "tonys-pizza"))
+	ops := issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("ops", "admin", "tonys-pizza"))
+
+	testCases := []struct {
+		name, token, id, restaurant string
+		want                        int
+	}{
+		{"own item", tonys, "1", "Tony's Pizza", http.StatusOK},
+		{"new item", tonys, "42", "Tony's Pizza", http.StatusCreated},
+		{"new item for another restaurant", tonys, "43", "Thai Palace", http.StatusForbidden},
+		{"another restaurant's item", tonys, "2", "Tony's Pizza", http.StatusForbidden},
+		{"moving an item away", tonys, "1", "Thai Palace", http.StatusForbidden},
+		{"without menu:write", reader, "1", "Tony's Pizza", http.StatusForbidden},
+		{"admin", ops, "3", "Burger Joint", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(MenuItem{ID: tc.id, Name: "Special", Price: 9.5, Restaurant: tc.restaurant, Category: "Pizza"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPut, "/api/menu/"+tc.id, bytes.NewReader(body))
+			req.Header = bearerHeader(tc.token)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rec.Code, rec.Body.String())
+			}
+			if tc.want == http.StatusForbidden {
+				var problem Problem
+				if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Code != errCodeForbidden {
+					t.Errorf("expected a FORBIDDEN problem, got %q", rec.Body.String())
+				}
+			}
+		})
+	}
+
+	rec := serveConditional(handler, "/api/menu/2", bearerHeader(reader))
+	if !strings.Contains(rec.Body.String(), "Chicken Pad Thai") {
+		t.Errorf("expected refused edits to leave the item alone, got %s", rec.Body.String())
+	}
+}
+
+func TestBearer_GRPC(t *testing.T) {
+	issuer := newTestIssuer(t)
+	_, conn := dialGRPC(t, newJWTServer(t, issuer))
+	client := menuv1.NewMenuServiceClient(conn)
+
+	for name, tc := range map[string]struct {
+		token string
+		want  codes.Code
+	}{
+		"valid":         {issuer.sign(t, jwt.SigningMethodES256, "ec-1", testClaims("alice", "menu:read", "")), codes.OK},
+		"missing scope": {issuer.sign(t, jwt.SigningMethodES256, "ec-1", testClaims("alice", "orders:write", "")), codes.PermissionDenied},
+		"garbage":       {"not-a-jwt", codes.Unauthenticated},
+	} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), grpcAuthorizationMetadata, "Bearer "+tc.token)
+		_, err := client.ListMenuItems(ctx, &menuv1.ListMenuItemsRequest{})
+		if got := status.Code(err); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", name, tc.want, got)
+		}
+	}
+}
+
+func TestConfig_ValidatesJWKSSource(t *testing.T) {
+	for name, mutate := range map[string]func(*AuthConfig){
+		"file and URL": func(c *AuthConfig) { c.JWKSFile, c.JWKSURL = "jwks.json", "https://id.example.com/jwks" },
+		"not HTTP":     func(c *AuthConfig) { c.JWKSURL = "ftp://id.example.com/jwks" },
+		"no refresh":   func(c *AuthConfig) { c.JWKSURL, c.JWKSRefresh = "https://id.example.com/jwks", 0 },
+	} {
+		cfg := defaultConfig()
+		mutate(&cfg.Auth)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "auth") {
+			t.Errorf("%s: expected an auth error, got %v", name, err)
+		}
+	}
+}
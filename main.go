@@ -2,38 +2,64 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/graphql-go/graphql"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-var tracer = otel.Tracer("github.com/blackswan/mock-go")
+var (
+	tracer = otel.Tracer("github.com/blackswan/mock-go")
+	meter  = otel.Meter("github.com/blackswan/mock-go")
+)
+
+// otlpEndpoint accepts the endpoint in either URL or host:port form, since
+// the exporters are handed a pre-dialed connection and skip that parsing.
+func otlpEndpoint(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return endpoint
+}
 
-func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
+func setupOTelSDK(ctx context.Context, cfg TelemetryConfig, health *healthRegistry) (shutdown func(context.Context) error, err error) {
 	var shutdownFuncs []func(context.Context) error
 
+	// Shut down in reverse order so providers flush before the shared
+	// exporter connection is closed.
 	shutdown = func(ctx context.Context) error {
 		var err error
-		for _, fn := range shutdownFuncs {
-			err = errors.Join(err, fn(ctx))
+		for i := len(shutdownFuncs) - 1; i >= 0; i-- {
+			err = errors.Join(err, shutdownFuncs[i](ctx))
 		}
 		shutdownFuncs = nil
 		return err
@@ -43,21 +69,29 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 		err = errors.Join(inErr, shutdown(ctx))
 	}
 
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName("friendly-octo-guacamole"),
-			semconv.ServiceVersion("1.0.0"),
-		),
+	res, err := newResource(ctx)
+	if errors.Is(err, resource.ErrPartialResource) {
+		log.Warn().Err(err).Msg("Some resource attributes could not be detected")
+	} else if err != nil {
+		handleErr(err)
+		return
+	}
+
+	conn, err := grpc.NewClient(otlpEndpoint(cfg.OTLPEndpoint),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
 	)
 	if err != nil {
 		handleErr(err)
 		return
 	}
+	shutdownFuncs = append(shutdownFuncs, func(context.Context) error { return conn.Close() })
+	// A collector outage loses telemetry but not the API, so it must not take
+	// every replica out of rotation.
+	health.RegisterOptional("otlp_exporter", probeReadiness, grpcConnCheck(conn))
 
 	traceExporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithGRPCConn(conn),
+		otlptracegrpc.WithHeaders(cfg.OTLPHeaders),
 	)
 	if err != nil {
 		handleErr(err)
@@ -75,6 +109,27 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 		propagation.Baggage{},
 	))
 
+	metricExporter, err := otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithGRPCConn(conn),
+		otlpmetricgrpc.WithHeaders(cfg.OTLPHeaders),
+	)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+	otel.SetMeterProvider(meterProvider)
+
+	if err = registerBuildInfoMetric(meter); err != nil {
+		handleErr(err)
+		return
+	}
+
 	return
 }
 
@@ -89,8 +144,62 @@ type MenuItem struct {
 	PrepTime    int     `json:"prep_time_minutes"`
 }
 
+// MenuListResponse is the body of GET /api/menu.
+type MenuListResponse struct {
+	MenuItems []MenuItem `json:"menu_items"`
+	Count     int        `json:"count"`
+}
+
+// MenuItemResponse is the body of GET /api/menu/{id}.
+type MenuItemResponse struct {
+	MenuItem MenuItem `json:"menu_item"`
+}
+
+// HealthResponse is the body of GET /health.
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+}
+
+// ErrorResponse is the body of every error written by writeError.
+type ErrorResponse struct {
+	Error      string      `json:"error"`
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
 type Server struct {
-	menuItems map[string]MenuItem
+	menu      MenuStore
+	menuCache *cachedMenuStore
+	tablets   *tabletHub
+	faults    atomic.Pointer[FaultConfig]
+	health    *healthRegistry
+	draining  atomic.Bool
+	inFlight  atomic.Int64
+
+	apiRequests metric.Int64Counter
+
+	responses *responseCache
+	httpCache atomic.Pointer[HTTPCacheConfig]
+
+	compression atomic.Pointer[CompressionConfig]
+	rateLimiter *rateLimiter
+	concurrency *concurrencyLimiter
+	timeouts    *routeTimeouts
+	auth        *authenticator
+
+	// adminListener moves the admin routes off the public handler when they
+	// are served on their own listener. It is set before handlers are built.
+	adminListener bool
+
+	graphQLSchema graphql.Schema
+	graphQLLimits atomic.Pointer[GraphQLConfig]
+
+	// closing is closed once drain starts stopping components, ending
+	// long-lived streams so servers can shut down.
+	closing chan struct{}
 }
 
 type responseWriter struct {
@@ -103,12 +212,38 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer to flush
+// streamed responses.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// logFields collects fields that handlers add to loggingMiddleware's line for
+// the request.
+type logFields struct {
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+type logFieldsKey struct{}
+
+// addLogField adds a field to the request's log line. It does nothing for
+// requests that are not logged.
+func addLogField(ctx context.Context, key, value string) {
+	if f, ok := ctx.Value(logFieldsKey{}).(*logFields); ok {
+		f.mu.Lock()
+		f.fields[key] = value
+		f.mu.Unlock()
+	}
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		fields := &logFields{fields: map[string]string{}}
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		next.ServeHTTP(rw, r)
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, fields)))
 
 		logger := log.Info()
 		if rw.statusCode >= 500 {
@@ -117,9 +252,19 @@ func loggingMiddleware(next http.Handler) http.Handler {
 			logger = log.Warn()
 		}
 
+		if encoding := rw.Header().Get("Content-Encoding"); encoding != "" {
+			logger = logger.Str("content_encoding", encoding)
+		}
+		fields.mu.Lock()
+		for _, key := range slices.Sorted(maps.Keys(fields.fields)) {
+			logger = logger.Str(key, fields.fields[key])
+		}
+		fields.mu.Unlock()
+
 		logger.
 			Str("method", r.Method).
 			Str("path", r.URL.Path).
+			Str("protocol", negotiatedProtocol(r)).
 			Int("status", rw.statusCode).
 			Dur("duration", time.Since(start)).
 			Str("remote_addr", r.RemoteAddr).
@@ -129,15 +274,97 @@ func loggingMiddleware(next http.Handler) http.Handler {
 }
 
 func NewServer() *Server {
-	return &Server{
-		menuItems: map[string]MenuItem{
-			"1": {ID: "1", Name: "Margherita Pizza", Price: 12.99, Available: true, Description: "Fresh mozzarella, tomato sauce, basil", Restaurant: "Tony's Pizza", Category: "Pizza", PrepTime: 20},
-			"2": {ID: "2", Name: "Chicken Pad Thai", Price: 14.99, Available: true, Description: "Rice noodles, chicken, peanuts, lime", Restaurant: "Thai Palace", Category: "Asian", PrepTime: 15},
-			"3": {ID: "3", Name: "Classic Burger", Price: 11.99, Available: false, Description: "Beef patty, lettuce, tomato, cheese", Restaurant: "Burger Joint", Category: "Burgers", PrepTime: 12},
-			"4": {ID: "4", Name: "Caesar Salad", Price: 8.99, Available: true, Description: "Romaine lettuce, parmesan, croutons", Restaurant: "Healthy Bites", Category: "Salads", PrepTime: 5},
-			"5": {ID: "5", Name: "Sushi Platter", Price: 24.99, Available: true, Description: "12 piece mixed sushi selection", Restaurant: "Sakura Sushi", Category: "Japanese", PrepTime: 25},
-		},
+	store := newMemoryMenuStore([]MenuItem{
+		{ID: "1", Name: "Margherita Pizza", Price: 12.99, Available: true, Description: "Fresh mozzarella, tomato sauce, basil", Restaurant: "Tony's Pizza", Category: "Pizza", PrepTime: 20},
+		{ID: "2", Name: "Chicken Pad Thai", Price: 14.99, Available: true, Description: "Rice noodles, chicken, peanuts, lime", Restaurant: "Thai Palace", Category: "Asian", PrepTime: 15},
+		{ID: "3", Name: "Classic Burger", Price: 11.99, Available: false, Description: "Beef patty, lettuce, tomato, cheese", Restaurant: "Burger Joint", Category: "Burgers", PrepTime: 12},
+		{ID: "4", Name: "Caesar Salad", Price: 8.99, Available: true, Description: "Romaine lettuce, parmesan, croutons", Restaurant: "Healthy Bites", Category: "Salads", PrepTime: 5},
+		{ID: "5", Name: "Sushi Platter", Price: 24.99, Available: true, Description: "12 piece mixed sushi selection", Restaurant: "Sakura Sushi", Category: "Japanese", PrepTime: 25},
+	})
+
+	s := &Server{
+		health:    newHealthRegistry(),
+		closing:   make(chan struct{}),
+		tablets:   newTabletHub(),
+		responses: newResponseCache(),
 	}
+
+	defaults := defaultConfig()
+	s.faults.Store(&defaults.Faults)
+	s.graphQLLimits.Store(&defaults.GraphQL)
+	s.httpCache.Store(&defaults.HTTPCache)
+	s.compression.Store(&defaults.Compression)
+
+	menuCache, err := newCachedMenuStore(store, defaults.MenuCache)
+	if err != nil {
+		panic(fmt.Sprintf("invalid menu cache: %v", err))
+	}
+	s.menu, s.menuCache = menuCache, menuCache
+
+	rateLimiter, err := newRateLimiter(defaults.RateLimit)
+	if err != nil {
+		panic(fmt.Sprintf("invalid rate limiter: %v", err))
+	}
+	s.rateLimiter = rateLimiter
+
+	concurrency, err := newConcurrencyLimiter(defaults.Concurrency)
+	if err != nil {
+		panic(fmt.Sprintf("invalid concurrency limiter: %v", err))
+	}
+	s.concurrency = concurrency
+
+	timeouts, err := newRouteTimeouts(defaults.Timeouts)
+	if err != nil {
+		panic(fmt.Sprintf("invalid route timeouts: %v", err))
+	}
+	s.timeouts = timeouts
+
+	auth, err := newAuthenticator(defaults.Auth)
+	if err != nil {
+		panic(fmt.Sprintf("invalid authenticator: %v", err))
+	}
+	s.auth = auth
+	s.rateLimiter.apiKeyID = auth.apiKeyID
+
+	apiRequests, err := meter.Int64Counter("api.requests",
+		metric.WithDescription("REST API requests by API version."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("invalid API request counter: %v", err))
+	}
+	s.apiRequests = apiRequests
+
+	schema, err := newGraphQLSchema(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+	s.graphQLSchema = schema
+
+	// Menu events are delivered in process, so there is no event publisher
+	// connection to check until one is added.
+	s.health.Register("menu_store", probeReadiness|probeStartup, s.checkMenuStore)
+	s.health.Register("draining", probeReadiness, s.checkNotDraining)
+
+	return s
+}
+
+func (s *Server) checkMenuStore(ctx context.Context) error {
+	items, err := s.menu.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("no menu items loaded")
+	}
+	return nil
+}
+
+func (s *Server) checkNotDraining(context.Context) error {
+	if s.draining.Load() {
+		return errors.New("server is draining")
+	}
+	return nil
 }
 
 func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
@@ -162,13 +389,35 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
 	return nil
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	errorResponse := map[string]string{
-		"error":   http.StatusText(status),
-		"message": message,
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// writeError writes an error body for r: an RFC 7807 Problem when the client
+// accepts application/problem+json, otherwise the original ErrorResponse.
+// Violations list the individual problems with a rejected request.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code errorCode, message string, violations ...Violation) {
+	var body any = ErrorResponse{
+		Error:      http.StatusText(status),
+		Message:    message,
+		Violations: violations,
+	}
+	contentType := "application/json"
+	if acceptsProblem(r) {
+		body = newProblem(r, status, code, message, violations)
+		contentType = problemContentType
 	}
+	addVary(w.Header(), "Accept")
 
-	data, err := json.Marshal(errorResponse)
+	data, err := json.Marshal(body)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -182,118 +431,383 @@ func writeError(w http.ResponseWriter, status int, message string) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	_, _ = w.Write(data)
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, _ *http.Request) {
-	_ = writeJSON(w, http.StatusOK, map[string]string{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
+	build := currentBuildInfo()
+	_ = writeJSON(w, http.StatusOK, HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Version:   build.Version,
+		Commit:    build.Commit,
 	})
 }
 
 func (s *Server) menuHandler(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "fetchMenuItems")
+	ctx, span := tracer.Start(r.Context(), "fetchMenuItems")
 	defer span.End()
 
-	if rand.Float32() < 0.1 {
+	if rand.Float64() < s.faults.Load().MenuErrorRate {
 		span.SetAttributes(attribute.Bool("error", true))
 		span.RecordError(errors.New("database connection failed"))
-		writeError(w, http.StatusInternalServerError, "Failed to fetch menu items from restaurant database")
+		writeError(w, r, http.StatusInternalServerError, errCodeMenuUnavailable, "Failed to fetch menu items from restaurant database")
 		return
 	}
 
-	menuList := make([]MenuItem, 0, len(s.menuItems))
-	for _, item := range s.menuItems {
-		menuList = append(menuList, item)
+	resp, cached, err := s.cachedMenuResponse(ctx, "list", func() (any, error) {
+		menuList, err := s.menu.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("menu.count", len(menuList)))
+		return menuListBody(ctx, menuList), nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeTimeout(w, r)
+		return
+	}
+	if err != nil {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeError(w, r, http.StatusInternalServerError, errCodeMenuUnavailable, "Failed to fetch menu items from restaurant database")
+		return
 	}
 
-	span.SetAttributes(attribute.Int("menu.count", len(menuList)))
-	_ = writeJSON(w, http.StatusOK, map[string]interface{}{
-		"menu_items": menuList,
-		"count":      len(menuList),
-	})
+	span.SetAttributes(attribute.Bool("menu.response.cached", cached))
+	s.writeCacheable(w, r, resp)
 }
 
 func (s *Server) menuItemByIDHandler(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "fetchMenuItemByID")
+	ctx, span := tracer.Start(r.Context(), "fetchMenuItemByID")
 	defer span.End()
 
-	menuItemID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/menu/"))
+	menuItemID := strings.TrimSpace(r.PathValue("id"))
 	span.SetAttributes(attribute.String("menu.item.id", menuItemID))
 
 	if menuItemID == "" {
 		span.SetAttributes(attribute.Bool("error", true))
-		writeError(w, http.StatusBadRequest, "Menu item ID is required")
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Menu item ID is required")
 		return
 	}
 
-	menuItem, exists := s.menuItems[menuItemID]
-	if !exists {
+	resp, cached, err := s.cachedMenuResponse(ctx, "item "+menuItemID, func() (any, error) {
+		menuItem, err := s.menu.Get(ctx, menuItemID)
+		if err != nil {
+			return nil, err
+		}
+		span.SetAttributes(
+			attribute.String("menu.item.name", menuItem.Name),
+			attribute.Float64("menu.item.price", menuItem.Price),
+		)
+		return menuItemBody(ctx, menuItem), nil
+	})
+	if errors.Is(err, ErrMenuItemNotFound) {
 		span.SetAttributes(attribute.Bool("error", true))
 		span.RecordError(fmt.Errorf("menu item not found: %s", menuItemID))
-		writeError(w, http.StatusNotFound, fmt.Sprintf("Menu item with ID '%s' not found", menuItemID))
+		writeError(w, r, http.StatusNotFound, errCodeMenuItemNotFound, fmt.Sprintf("Menu item with ID '%s' not found", menuItemID))
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeTimeout(w, r)
+		return
+	}
+	if err != nil {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeError(w, r, http.StatusInternalServerError, errCodeMenuUnavailable, "Failed to fetch menu item from restaurant database")
 		return
 	}
 
-	span.SetAttributes(
-		attribute.String("menu.item.name", menuItem.Name),
-		attribute.Float64("menu.item.price", menuItem.Price),
-	)
-	_ = writeJSON(w, http.StatusOK, map[string]interface{}{
-		"menu_item": menuItem,
-	})
+	span.SetAttributes(attribute.Bool("menu.response.cached", cached))
+	s.writeCacheable(w, r, resp)
+}
+
+// updateMenuItemHandler creates or replaces the item at the path's ID.
+// Callers tied to a restaurant may only edit that restaurant's items, and may
+// not move an item to another restaurant.
+func (s *Server) updateMenuItemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "updateMenuItem")
+	defer span.End()
+
+	menuItemID := strings.TrimSpace(r.PathValue("id"))
+	span.SetAttributes(attribute.String("menu.item.id", menuItemID))
+
+	var item MenuItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		span.SetAttributes(attribute.Bool("error", true))
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Request body must be a menu item")
+		return
+	}
+	if menuItemID == "" || item.ID != menuItemID {
+		span.SetAttributes(attribute.Bool("error", true))
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Menu item ID must match the path")
+		return
+	}
+
+	existing, err := s.menu.Get(ctx, menuItemID)
+	created := errors.Is(err, ErrMenuItemNotFound)
+	if err == nil || created {
+		p := principalFrom(ctx)
+		if p != nil && (!p.mayActFor(item.Restaurant) || !created && !p.mayActFor(existing.Restaurant)) {
+			span.SetAttributes(attribute.Bool("error", true))
+			writeError(w, r, http.StatusForbidden, errCodeForbidden,
+				fmt.Sprintf("Not allowed to edit the menu of restaurant '%s'", item.Restaurant))
+			return
+		}
+		err = s.menu.Put(ctx, item)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeTimeout(w, r)
+		return
+	}
+	if err != nil {
+		span.SetAttributes(attribute.Bool("error", true))
+		span.RecordError(err)
+		writeError(w, r, http.StatusInternalServerError, errCodeMenuUnavailable, "Failed to save menu item to restaurant database")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	span.SetAttributes(attribute.Bool("menu.item.created", created))
+	_ = writeJSON(w, status, menuItemBody(ctx, item))
+}
+
+// cachedMenuResponse returns the encoded response for key in the request's API
+// version at the menu's current revision, building it on a miss.
+func (s *Server) cachedMenuResponse(ctx context.Context, key string, build func() (any, error)) (encodedResponse, bool, error) {
+	rev, err := s.menu.Revision(ctx)
+	if err != nil {
+		return encodedResponse{}, false, err
+	}
+	return s.responses.get(string(apiVersionFrom(ctx))+" "+key, rev, build)
 }
 
 func newHTTPHandler(server *Server) http.Handler {
 	mux := http.NewServeMux()
 
-	handleFunc := func(pattern string, handlerFunc func(http.ResponseWriter, *http.Request)) {
-		handler := otelhttp.WithRouteTag(pattern, http.HandlerFunc(handlerFunc))
-		mux.Handle(pattern, handler)
+	routes := server.routes()
+	spec := newOpenAPIDocument(routes)
+	for _, rt := range routes {
+		if server.adminListener && rt.admin() {
+			continue
+		}
+		handler := validateRequests(spec, rt, server.timeouts.limit(rt, rt.handler))
+		handler = server.rateLimiter.limit(rt, server.auth.require(rt, handler))
+		handler = server.concurrency.limitRoute(rt, handler)
+		mux.Handle(rt.pattern, otelhttp.WithRouteTag(rt.path(), handler))
+	}
+	mux.Handle("/", unmatchedHandler(mux))
+
+	return otelhttp.NewHandler(protocolMiddleware(server.compressionMiddleware(mux)), "/")
+}
+
+// newAdminHandler serves the admin routes of server, validated and bounded
+// like on the public listener but without authentication, rate or
+// concurrency limits, none of which apply to them.
+func newAdminHandler(server *Server) http.Handler {
+	mux := http.NewServeMux()
+
+	routes := server.routes()
+	spec := newOpenAPIDocument(routes)
+	for _, rt := range routes {
+		if !rt.admin() {
+			continue
+		}
+		handler := validateRequests(spec, rt, server.timeouts.limit(rt, rt.handler))
+		mux.Handle(rt.pattern, otelhttp.WithRouteTag(rt.path(), handler))
+	}
+	mux.Handle("/", unmatchedHandler(mux))
+
+	return otelhttp.NewHandler(mux, "/admin")
+}
+
+// runConfigCommand implements `config print`, which shows the effective
+// configuration for the given flags and environment with secrets redacted.
+func runConfigCommand(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: mock-service config print [flags]")
 	}
 
-	handleFunc("/health", server.healthHandler)
-	handleFunc("/api/menu", server.menuHandler)
-	handleFunc("/api/menu/", server.menuItemByIDHandler)
+	cfg, err := loadConfig("config print", args[1:], os.Getenv)
+	if err != nil {
+		return err
+	}
+	return printConfig(os.Stdout, cfg)
+}
 
-	return otelhttp.NewHandler(mux, "/")
+// serveHTTP runs srv, over TLS when it has a TLS config, and exits the
+// process if it fails for any reason other than being shut down.
+func serveHTTP(srv *http.Server, name string) {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal().Err(err).Msgf("%s failed to start", name)
+	}
 }
 
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfigCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := runAPIKeyCommand(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	loadCfg := func() (Config, error) { return loadConfig(os.Args[0], os.Args[1:], os.Getenv) }
+	cfg, err := loadCfg()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	applyLogLevel(cfg)
+
+	server := NewServer()
+	server.faults.Store(&cfg.Faults)
+	server.graphQLLimits.Store(&cfg.GraphQL)
+	server.httpCache.Store(&cfg.HTTPCache)
+	server.menuCache.config.Store(&cfg.MenuCache)
+	server.compression.Store(&cfg.Compression)
+	server.rateLimiter.configure(cfg.RateLimit)
+	server.concurrency.reset(cfg.Concurrency)
+	server.timeouts.configure(cfg.Timeouts)
+	server.auth.configure(cfg.Auth)
+
+	reloader := newConfigReloader(cfg, loadCfg)
+	reloader.OnReload(applyLogLevel)
+	reloader.OnReload(func(c Config) { server.faults.Store(&c.Faults) })
+	reloader.OnReload(func(c Config) { server.graphQLLimits.Store(&c.GraphQL) })
+	reloader.OnReload(func(c Config) { server.httpCache.Store(&c.HTTPCache) })
+	reloader.OnReload(func(c Config) { server.menuCache.config.Store(&c.MenuCache) })
+	reloader.OnReload(func(c Config) { server.compression.Store(&c.Compression) })
+	reloader.OnReload(func(c Config) { server.rateLimiter.configure(c.RateLimit) })
+	reloader.OnReload(func(c Config) { server.concurrency.config.Store(&c.Concurrency) })
+	reloader.OnReload(func(c Config) { server.timeouts.configure(c.Timeouts) })
+	reloader.OnReload(func(c Config) { server.auth.configure(c.Auth) })
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go reloader.watchSignals(watchCtx)
+	if cfg.File != "" {
+		go reloader.watchFile(watchCtx, cfg.File, filePollInterval)
+	}
+	if cfg.Auth.APIKeysFile != "" {
+		go server.auth.watch(watchCtx, filePollInterval, func() AuthConfig { return reloader.Current().Auth })
+	}
+
 	ctx := context.Background()
-	otelShutdown, err := setupOTelSDK(ctx)
+	otelShutdown, err := setupOTelSDK(ctx, cfg.Telemetry, server.health)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to setup OpenTelemetry SDK")
 	}
-	defer func() {
-		if err := otelShutdown(ctx); err != nil {
-			log.Error().Err(err).Msg("Failed to shutdown OpenTelemetry SDK")
-		}
-	}()
 
-	server := NewServer()
+	if err := registerTabletMetrics(meter, server.tablets); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register tablet metrics")
+	}
+	if err := registerConcurrencyMetrics(meter, server.concurrency); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register concurrency metrics")
+	}
+	if cfg.Orders.SimulateInterval > 0 {
+		go server.simulateOrders(watchCtx, cfg.Orders.SimulateInterval)
+	}
+
+	server.adminListener = cfg.Admin.Enabled()
 	handler := newHTTPHandler(server)
 
 	httpServer := &http.Server{
-		Addr:         ":8080",
-		Handler:      loggingMiddleware(handler),
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         cfg.Server.Addr,
+		Handler:      loggingMiddleware(server.inFlightMiddleware(handler)),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Protocols:    httpProtocols(cfg.Server.Protocols),
 	}
 
-	log.Info().Msgf("Starting server on %s", httpServer.Addr)
+	var certs *tlsReloader
+	if cfg.Server.TLS.Enabled() {
+		certs, err = newTLSReloader(cfg.Server.TLS, cfg.Admin.ClientCAFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load TLS certificate")
+		}
+		if err := registerCertExpiryMetric(meter, certs); err != nil {
+			log.Fatal().Err(err).Msg("Failed to register TLS certificate metric")
+		}
+		httpServer.TLSConfig = certs.serverConfig(alpnProtocols(cfg.Server.Protocols), tls.NoClientCert)
+		go certs.watch(watchCtx, filePollInterval)
+	}
 
-	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal().Err(err).Msgf("Server failed to start")
+	var adminServer *http.Server
+	if cfg.Admin.Enabled() {
+		adminServer = &http.Server{
+			Addr:         cfg.Admin.Addr,
+			Handler:      loggingMiddleware(newAdminHandler(server)),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		if certs != nil {
+			adminServer.TLSConfig = certs.serverConfig([]string{"h2", "http/1.1"}, clientAuthTypes[cfg.Admin.ClientAuth])
 		}
-	}()
+		log.Info().
+			Bool("tls", certs != nil).
+			Str("client_auth", cfg.Admin.ClientAuth).
+			Msgf("Starting admin server on %s", cfg.Admin.Addr)
+		go serveHTTP(adminServer, "Admin server")
+	}
+
+	var grpcSrv *grpcServer
+	if cfg.GRPC.Enabled() {
+		var grpcTLS *tls.Config
+		if certs != nil {
+			grpcTLS = certs.serverConfig([]string{"h2"}, tls.NoClientCert)
+		}
+		grpcSrv = newGRPCServer(server, grpcTLS)
+
+		lis, err := net.Listen("tcp", cfg.GRPC.Addr)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to listen for gRPC")
+		}
+		log.Info().Bool("tls", grpcTLS != nil).Msgf("Starting gRPC server on %s", cfg.GRPC.Addr)
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatal().Err(err).Msg("gRPC server failed")
+			}
+		}()
+	}
+
+	build := currentBuildInfo()
+	log.Info().
+		Str("version", build.Version).
+		Str("commit", build.Commit).
+		Str("build_time", build.BuildTime).
+		Bool("tls", cfg.Server.TLS.Enabled()).
+		Strs("protocols", cfg.Server.Protocols).
+		Msgf("Starting server on %s", httpServer.Addr)
+
+	go serveHTTP(httpServer, "Server")
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -301,10 +815,23 @@ func main() {
 
 	log.Info().Msg("Shutting down server...")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	steps := []shutdownStep{{name: "http_server", fn: httpServer.Shutdown}}
+	if grpcSrv != nil {
+		steps = append(steps, shutdownStep{name: "grpc_server", fn: grpcSrv.shutdown})
+	}
+	if adminServer != nil {
+		steps = append(steps, shutdownStep{name: "admin_server", fn: adminServer.Shutdown})
+	}
+	steps = append(steps,
+		shutdownStep{name: "file_watchers", fn: func(context.Context) error {
+			stopWatching()
+			return nil
+		}},
+		shutdownStep{name: "telemetry", fn: otelShutdown, reserve: cfg.Shutdown.TelemetryFlush},
+	)
 
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+	err = server.drain(cfg.Shutdown, steps...)
+	if err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
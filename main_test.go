@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -20,26 +21,31 @@ func TestNewServer(t *testing.T) {
 		t.Fatal("NewServer returned nil")
 	}
 
-	if server.menuItems == nil {
-		t.Fatal("menuItems map is nil")
+	if server.menu == nil {
+		t.Fatal("menu store is nil")
+	}
+
+	menuItems, err := server.menu.List(context.Background())
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
 	}
 
 	expectedCount := 5
-	if len(server.menuItems) != expectedCount {
-		t.Errorf("expected %d menu items, got %d", expectedCount, len(server.menuItems))
+	if len(menuItems) != expectedCount {
+		t.Errorf("expected %d menu items, got %d", expectedCount, len(menuItems))
 	}
 
 	// Verify all expected IDs exist
 	expectedIDs := []string{"1", "2", "3", "4", "5"}
 	for _, id := range expectedIDs {
-		if _, exists := server.menuItems[id]; !exists {
+		if _, err := server.menu.Get(context.Background(), id); err != nil {
 			t.Errorf("expected menu item with ID %q to exist", id)
 		}
 	}
 
 	// Verify a specific menu item
-	item, exists := server.menuItems["1"]
-	if !exists {
+	item, err := server.menu.Get(context.Background(), "1")
+	if err != nil {
 		t.Fatal("menu item '1' not found")
 	}
 	if item.Name != "Margherita Pizza" {
@@ -205,7 +211,7 @@ func TestWriteJSON_UnmarshalableData(t *testing.T) {
 func TestWriteError_BasicError(t *testing.T) {
 	rec := httptest.NewRecorder()
 
-	writeError(rec, http.StatusBadRequest, "Invalid input")
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusBadRequest, errCodeInvalidRequest, "Invalid input")
 
 	if rec.Code != http.StatusBadRequest {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
@@ -245,7 +251,7 @@ func TestWriteError_DifferentStatusCodes(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.expectedError, func(t *testing.T) {
 			rec := httptest.NewRecorder()
-			writeError(rec, tc.status, "test message")
+			writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tc.status, errCodeInvalidRequest, "test message")
 
 			if rec.Code != tc.status {
 				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
@@ -466,6 +472,7 @@ func TestMenuItemByIDHandler_ValidID(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run("ID_"+tc.id, func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/api/menu/"+tc.id, nil)
+			req.SetPathValue("id", tc.id)
 			rec := httptest.NewRecorder()
 
 			server.menuItemByIDHandler(rec, req)
@@ -516,6 +523,7 @@ func TestMenuItemByIDHandler_WhitespaceID(t *testing.T) {
 	server := NewServer()
 	// Use URL encoding for whitespace to avoid httptest.NewRequest panic
 	req := httptest.NewRequest(http.MethodGet, "/api/menu/%20%20%20", nil)
+	req.SetPathValue("id", "   ")
 	rec := httptest.NewRecorder()
 
 	server.menuItemByIDHandler(rec, req)
@@ -534,6 +542,7 @@ func TestMenuItemByIDHandler_NonExistentID(t *testing.T) {
 	for _, id := range testCases {
 		t.Run("ID_"+id, func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/api/menu/"+id, nil)
+			req.SetPathValue("id", id)
 			rec := httptest.NewRecorder()
 
 			server.menuItemByIDHandler(rec, req)
@@ -562,6 +571,7 @@ func TestMenuItemByIDHandler_NonExistentID(t *testing.T) {
 func TestMenuItemByIDHandler_ResponseStructure(t *testing.T) {
 	server := NewServer()
 	req := httptest.NewRequest(http.MethodGet, "/api/menu/1", nil)
+	req.SetPathValue("id", "1")
 	rec := httptest.NewRecorder()
 
 	server.menuItemByIDHandler(rec, req)
@@ -707,9 +717,9 @@ func TestIntegration_FullServerRouting(t *testing.T) {
 	server := NewServer()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", server.healthHandler)
-	mux.HandleFunc("/api/menu", server.menuHandler)
-	mux.HandleFunc("/api/menu/", server.menuItemByIDHandler)
+	mux.HandleFunc("GET /health", server.healthHandler)
+	mux.HandleFunc("GET /api/menu", server.menuHandler)
+	mux.HandleFunc("GET /api/menu/{id}", server.menuItemByIDHandler)
 
 	handler := loggingMiddleware(mux)
 
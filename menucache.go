@@ -0,0 +1,218 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// Results of a cachedMenuStore read, recorded on metrics and spans.
+const (
+	menuCacheHit       = "hit"
+	menuCacheMiss      = "miss"
+	menuCacheCoalesced = "coalesced"
+)
+
+// Keys of cached reads. Items are keyed by menuCacheItemPrefix plus their ID.
+const (
+	menuCacheList       = "list"
+	menuCacheRevision   = "revision"
+	menuCacheItemPrefix = "item:"
+)
+
+// cachedMenuStore is a MenuStore that keeps List, Get and Revision results in
+// memory for a TTL, evicting the least recently used entry beyond MaxEntries.
+// Concurrent misses for the same key share a single read of the underlying
+// store. Writes go through to the store and drop the entries they affect;
+// writes made to the store directly are only seen once the TTL expires.
+type cachedMenuStore struct {
+	MenuStore
+
+	config   atomic.Pointer[MenuCacheConfig]
+	loads    singleflight.Group
+	requests metric.Int64Counter
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *menuCacheEntry, most recently used first
+	// generation advances with every invalidation, so a read that started
+	// before a write does not cache what it read.
+	generation uint64
+}
+
+type menuCacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func newCachedMenuStore(store MenuStore, cfg MenuCacheConfig) (*cachedMenuStore, error) {
+	requests, err := meter.Int64Counter("menu.cache.requests",
+		metric.WithDescription("Menu store reads served by the in-process cache, by result."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("menu cache request counter: %w", err)
+	}
+
+	c := &cachedMenuStore{
+		MenuStore: store,
+		requests:  requests,
+		entries:   map[string]*list.Element{},
+		lru:       list.New(),
+	}
+	c.config.Store(&cfg)
+	return c, nil
+}
+
+func (c *cachedMenuStore) List(ctx context.Context) ([]MenuItem, error) {
+	items, err := cachedRead(ctx, c, menuCacheList, c.MenuStore.List)
+	// Callers own the slice they get back.
+	return slices.Clone(items), err
+}
+
+func (c *cachedMenuStore) Get(ctx context.Context, id string) (MenuItem, error) {
+	return cachedRead(ctx, c, menuCacheItemPrefix+id, func(ctx context.Context) (MenuItem, error) {
+		return c.MenuStore.Get(ctx, id)
+	})
+}
+
+func (c *cachedMenuStore) Revision(ctx context.Context) (MenuRevision, error) {
+	return cachedRead(ctx, c, menuCacheRevision, c.MenuStore.Revision)
+}
+
+func (c *cachedMenuStore) Put(ctx context.Context, item MenuItem) error {
+	defer c.invalidate(menuCacheItemPrefix + item.ID)
+	return c.MenuStore.Put(ctx, item)
+}
+
+func (c *cachedMenuStore) Delete(ctx context.Context, id string) error {
+	defer c.invalidate(menuCacheItemPrefix + id)
+	return c.MenuStore.Delete(ctx, id)
+}
+
+// invalidate drops key along with the listing and revision, which every
+// write changes. It runs after the write so that no read between the two can
+// cache the old value.
+func (c *cachedMenuStore) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, k := range []string{key, menuCacheList, menuCacheRevision} {
+		if e, ok := c.entries[k]; ok {
+			c.lru.Remove(e)
+			delete(c.entries, k)
+		}
+		// Later reads must not join a load that may predate the write.
+		c.loads.Forget(k)
+	}
+}
+
+// cachedRead returns the cached value of key, or loads it with read. Errors,
+// including ErrMenuItemNotFound, are not cached.
+func cachedRead[T any](ctx context.Context, c *cachedMenuStore, key string, read func(context.Context) (T, error)) (T, error) {
+	cfg := c.config.Load()
+	if cfg.TTL <= 0 {
+		return read(ctx)
+	}
+
+	if v, ok := c.lookup(key); ok {
+		c.record(ctx, key, menuCacheHit)
+		return v.(T), nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	// The load is shared, so one caller giving up must not cancel it for the
+	// others; each caller still stops waiting when its own context ends.
+	results := c.loads.DoChan(key, func() (any, error) {
+		v, err := read(context.WithoutCancel(ctx))
+		if err == nil {
+			c.store(key, v, generation, cfg)
+		}
+		return v, err
+	})
+
+	var zero T
+	select {
+	case res := <-results:
+		result := menuCacheMiss
+		if res.Shared {
+			result = menuCacheCoalesced
+		}
+		c.record(ctx, key, result)
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+func (c *cachedMenuStore) lookup(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*menuCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.value, true
+}
+
+// store caches value unless an invalidation happened since generation.
+func (c *cachedMenuStore) store(key string, value any, generation uint64, cfg *MenuCacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	entry := &menuCacheEntry{key: key, value: value, expires: time.Now().Add(cfg.TTL)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[key] = c.lru.PushFront(entry)
+	}
+	for c.lru.Len() > max(cfg.MaxEntries, 1) {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*menuCacheEntry).key)
+	}
+}
+
+// record counts a read and notes its result on the caller's span, as e.g.
+// menu.cache.list=hit. Item reads share the menu.cache.item attribute.
+func (c *cachedMenuStore) record(ctx context.Context, key, result string) {
+	operation := key
+	if strings.HasPrefix(key, menuCacheItemPrefix) {
+		operation = "item"
+	}
+	c.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("menu.cache.operation", operation),
+		attribute.String("menu.cache.result", result),
+	))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("menu.cache."+operation, result))
+}
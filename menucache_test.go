@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// countingStore counts reads of the wrapped store. While gate is non-nil,
+// reads block until it is closed.
+type countingStore struct {
+	MenuStore
+	lists, gets atomic.Int64
+	gate        chan struct{}
+}
+
+func (s *countingStore) List(ctx context.Context) ([]MenuItem, error) {
+	s.lists.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.MenuStore.List(ctx)
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (MenuItem, error) {
+	s.gets.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.MenuStore.Get(ctx, id)
+}
+
+func newTestMenuCache(t *testing.T, cfg MenuCacheConfig) (*cachedMenuStore, *countingStore) {
+	t.Helper()
+
+	store := &countingStore{MenuStore: newMemoryMenuStore([]MenuItem{
+		{ID: "1", Name: "Margherita Pizza", Price: 12.99},
+		{ID: "2", Name: "Chicken Pad Thai", Price: 14.99},
+		{ID: "3", Name: "Classic Burger", Price: 11.99},
+	})}
+	cache, err := newCachedMenuStore(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache, store
+}
+
+func TestMenuCache_ServesRepeatReadsFromMemory(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+
+	for range 3 {
+		items, err := cache.List(ctx)
+		if err != nil || len(items) != 3 {
+			t.Fatalf("List = %v, %v", items, err)
+		}
+		items[0].Name = "mutated"
+		if _, err := cache.Get(ctx, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.lists.Load() != 1 || store.gets.Load() != 1 {
+		t.Errorf("expected one read of each, got %d lists and %d gets", store.lists.Load(), store.gets.Load())
+	}
+
+	items, _ := cache.List(ctx)
+	if items[0].Name != "Margherita Pizza" {
+		t.Errorf("a caller's changes leaked into the cache: %q", items[0].Name)
+	}
+}
+
+func TestMenuCache_DoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+
+	for range 2 {
+		if _, err := cache.Get(ctx, "42"); err != ErrMenuItemNotFound {
+			t.Fatalf("expected ErrMenuItemNotFound, got %v", err)
+		}
+	}
+	if store.gets.Load() != 2 {
+		t.Errorf("expected misses to reach the store, got %d gets", store.gets.Load())
+	}
+}
+
+func TestMenuCache_ExpiresAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: 10 * time.Millisecond, MaxEntries: 10})
+
+	_, _ = cache.Get(ctx, "1")
+	time.Sleep(20 * time.Millisecond)
+	_, _ = cache.Get(ctx, "1")
+	if store.gets.Load() != 2 {
+		t.Errorf("expected an expired entry to be reread, got %d gets", store.gets.Load())
+	}
+
+	cache.config.Store(&MenuCacheConfig{MaxEntries: 10})
+	_, _ = cache.Get(ctx, "1")
+	_, _ = cache.Get(ctx, "1")
+	if store.gets.Load() != 4 {
+		t.Errorf("expected a zero TTL to read through, got %d gets", store.gets.Load())
+	}
+}
+
+func TestMenuCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 2})
+
+	_, _ = cache.Get(ctx, "1")
+	_, _ = cache.Get(ctx, "2")
+	_, _ = cache.Get(ctx, "1") // 2 is now least recently used
+	_, _ = cache.Get(ctx, "3")
+	if store.gets.Load() != 3 {
+		t.Fatalf("expected 3 gets, got %d", store.gets.Load())
+	}
+
+	_, _ = cache.Get(ctx, "1")
+	if store.gets.Load() != 3 {
+		t.Errorf("expected item 1 to stay cached, got %d gets", store.gets.Load())
+	}
+	_, _ = cache.Get(ctx, "2")
+	if store.gets.Load() != 4 {
+		t.Errorf("expected item 2 to be evicted, got %d gets", store.gets.Load())
+	}
+}
+
+func TestMenuCache_CoalescesConcurrentMisses(t *testing.T) {
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+	store.gate = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if items, err := cache.List(context.Background()); err != nil || len(items) != 3 {
+				t.Errorf("List = %v, %v", items, err)
+			}
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(store.gate)
+	wg.Wait()
+
+	if store.lists.Load() != 1 {
+		t.Errorf("expected concurrent misses to share one read, got %d", store.lists.Load())
+	}
+}
+
+func TestMenuCache_WaiterStopsOnCancel(t *testing.T) {
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+	store.gate = make(chan struct{})
+	defer close(store.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.List(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestMenuCache_WritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+
+	before, _ := cache.Revision(ctx)
+	_, _ = cache.List(ctx)
+	_, _ = cache.Get(ctx, "1")
+
+	if err := cache.Put(ctx, MenuItem{ID: "1", Name: "Margherita Pizza", Price: 13.49}); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := cache.Get(ctx, "1"); item.Price != 13.49 {
+		t.Errorf("expected the updated price, got %v", item.Price)
+	}
+	if items, _ := cache.List(ctx); items[0].Price != 13.49 {
+		t.Errorf("expected the listing to show the updated price, got %v", items[0].Price)
+	}
+	if after, _ := cache.Revision(ctx); after.ID == before.ID {
+		t.Errorf("expected a new revision after Put, stayed %d", after.ID)
+	}
+
+	if err := cache.Delete(ctx, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := cache.List(ctx); len(items) != 2 {
+		t.Errorf("expected 2 items after Delete, got %d", len(items))
+	}
+}
+
+func TestMenuCache_DropsReadsThatRaceAWrite(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+	store.gate = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cache.Get(ctx, "1")
+	}()
+	for store.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := cache.Put(ctx, MenuItem{ID: "1", Name: "Margherita Pizza", Price: 13.49}); err != nil {
+		t.Fatal(err)
+	}
+	close(store.gate)
+	<-done
+
+	if item, _ := cache.Get(ctx, "1"); item.Price != 13.49 {
+		t.Errorf("a read that raced the write was cached: price %v", item.Price)
+	}
+}
+
+func TestMenuCache_CountsHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	cache, _ := newTestMenuCache(t, MenuCacheConfig{TTL: time.Minute, MaxEntries: 10})
+	counter, err := provider.Meter("test").Int64Counter("menu.cache.requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.requests = counter
+
+	_, _ = cache.List(ctx)
+	_, _ = cache.List(ctx)
+	_, _ = cache.List(ctx)
+	_, _ = cache.Get(ctx, "1")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[[2]string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || m.Name != "menu.cache.requests" {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				operation, _ := dp.Attributes.Value(attribute.Key("menu.cache.operation"))
+				result, _ := dp.Attributes.Value(attribute.Key("menu.cache.result"))
+				got[[2]string{operation.AsString(), result.AsString()}] = dp.Value
+			}
+		}
+	}
+
+	want := map[[2]string]int64{
+		{"list", menuCacheMiss}: 1,
+		{"list", menuCacheHit}:  2,
+		{"item", menuCacheMiss}: 1,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %v to be %d, got %d (all: %v)", k, v, got[k], got)
+		}
+	}
+}
+
+func TestMenuCache_RecordsResultOnSpan(t *testing.T) {
+	recorder := installSpanRecorder()
+	server := newTestServerWithoutFaults()
+	handler := newHTTPHandler(server)
+	seen := len(recorder.Ended())
+
+	// The second request rebuilds the response in v2, so it reads the list
+	// again, this time from the store cache.
+	serveConditional(handler, "/api/menu", nil)
+	serveConditional(handler, "/api/v2/menu", nil)
+
+	var results []string
+	for _, span := range recorder.Ended()[seen:] {
+		if span.Name() != "fetchMenuItems" {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "menu.cache.list" {
+				results = append(results, attr.Value.AsString())
+			}
+		}
+	}
+	if len(results) != 2 || results[0] != menuCacheMiss || results[1] != menuCacheHit {
+		t.Errorf("expected menu.cache.list to be miss then hit, got %v", results)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web/docs.html
+var docsPage []byte
+
+// apiOperation documents a route for the OpenAPI spec. The method and path
+// come from the route's pattern.
+type apiOperation struct {
+	ID          string
+	Summary     string
+	Tags        []string
+	Parameters  []apiParameter
+	RequestBody any
+	Responses   []apiResponse
+	// Scope is what an API key needs to call the operation once keys are
+	// configured. Operations without one are public.
+	Scope scope
+}
+
+type apiParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+// apiResponse documents a status code. Body is a value of the Go type that is
+// serialized, so the schema follows its JSON tags; ContentType defaults to
+// application/json when Body is set. Negotiated lists further media types the
+// client can ask for via Accept, with their bodies.
+type apiResponse struct {
+	Status      int
+	Description string
+	Body        any
+	ContentType string
+	Negotiated  map[string]any
+}
+
+// jsonSchema is the subset of OpenAPI 3.0 schema objects the service uses.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*jsonSchema           `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []apiParameter             `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema,omitempty"`
+}
+
+// newOpenAPIDocument describes every documented operation of routes.
+func newOpenAPIDocument(routes []route) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "Menu API",
+			Description: "Menu service of the food delivery platform.",
+			Version:     currentBuildInfo().Version,
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*jsonSchema{},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"apiKey": {Type: "apiKey", In: "header", Name: apiKeyHeader, Description: "Required once API keys are configured."},
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "RS256 or ES256 token from the identity provider, accepted once a JWKS is configured."},
+			},
+		},
+	}
+
+	// Any route may be rate limited, all but health checks shed, and all but
+	// streams time out.
+	rateLimited := errorResponses(http.StatusTooManyRequests)
+	overloaded := errorResponses(http.StatusServiceUnavailable)
+	timedOut := errorResponses(http.StatusGatewayTimeout)
+
+	for _, rt := range routes {
+		op, path := rt.operation, rt.path()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+
+		out := &openAPIOperation{
+			OperationID: op.ID,
+			Summary:     op.Summary,
+			Tags:        op.Tags,
+			Parameters:  op.Parameters,
+			Responses:   map[string]openAPIResponse{},
+		}
+		if op.RequestBody != nil {
+			out.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  map[string]openAPIMediaType{"application/json": {Schema: doc.schemaFor(reflect.TypeOf(op.RequestBody))}},
+			}
+		}
+		responses := slices.Concat(op.Responses, rateLimited)
+		if rt.priority() != priorityCritical {
+			responses = append(responses, overloaded...)
+		}
+		if !rt.streaming() {
+			responses = append(responses, timedOut...)
+		}
+		if op.Scope != "" {
+			out.Security = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+			denied := errorResponses(http.StatusUnauthorized, http.StatusForbidden)
+			denied[1].Description = "The credentials lack the " + string(op.Scope) + " scope"
+			responses = append(responses, denied...)
+		}
+		for _, resp := range responses {
+			r := openAPIResponse{Description: resp.Description}
+			switch {
+			case resp.Body != nil:
+				contentType := resp.ContentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				r.Content = map[string]openAPIMediaType{contentType: {Schema: doc.schemaFor(reflect.TypeOf(resp.Body))}}
+			case resp.ContentType != "":
+				r.Content = map[string]openAPIMediaType{resp.ContentType: {}}
+			}
+			for contentType, body := range resp.Negotiated {
+				if r.Content == nil {
+					r.Content = map[string]openAPIMediaType{}
+				}
+				r.Content[contentType] = openAPIMediaType{Schema: doc.schemaFor(reflect.TypeOf(body))}
+			}
+			out.Responses[strconv.Itoa(resp.Status)] = r
+		}
+		doc.Paths[path][strings.ToLower(rt.method())] = out
+	}
+	return doc
+}
+
+// schemaFor maps a Go type to a schema following encoding/json rules. Named
+// structs become components referenced by name.
+func (d *openAPIDocument) schemaFor(t reflect.Type) *jsonSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			d.Components.Schemas[name] = nil // guards recursive types
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return &jsonSchema{Ref: "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return &jsonSchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &jsonSchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &jsonSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &jsonSchema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return d.structSchema(t)
+	default:
+		return &jsonSchema{}
+	}
+}
+
+func (d *openAPIDocument) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// openAPIHandler serves the spec for routes, built on first use.
+func openAPIHandler(routes func() []route) http.HandlerFunc {
+	spec := sync.OnceValue(func() *openAPIDocument { return newOpenAPIDocument(routes()) })
+	return func(w http.ResponseWriter, _ *http.Request) {
+		_ = writeJSON(w, http.StatusOK, spec())
+	}
+}
+
+func docsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+// pathParam, queryParam and errorResponses keep the route table readable.
+func pathParam(name, description string) apiParameter {
+	one := 1
+	return apiParameter{Name: name, In: "path", Description: description, Required: true, Schema: &jsonSchema{Type: "string", MinLength: &one}}
+}
+
+func queryParam(name, description string, required bool, schema *jsonSchema) apiParameter {
+	return apiParameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+func errorResponses(statuses ...int) []apiResponse {
+	var out []apiResponse
+	for _, status := range statuses {
+		out = append(out, apiResponse{
+			Status:      status,
+			Description: http.StatusText(status),
+			Body:        ErrorResponse{},
+			Negotiated:  map[string]any{problemContentType: Problem{}},
+		})
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func fetchOpenAPI(t *testing.T, handler http.Handler) openAPIDocument {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to decode spec: %v", err)
+	}
+	return doc
+}
+
+// servedOperations is every operation the public listener serves, kept by
+// hand so the spec and the mux are both checked against something other
+// than the route table that builds them.
+var servedOperations = []string{
+	"GET /health",
+	"GET /version",
+	"GET /livez",
+	"GET /readyz",
+	"GET /startupz",
+	"GET /api/menu",
+	"GET /api/menu/{id}",
+	"PUT /api/menu/{id}",
+	"GET /api/menu/stream",
+	"GET /api/v1/menu",
+	"GET /api/v1/menu/{id}",
+	"GET /api/v2/menu",
+	"GET /api/v2/menu/{id}",
+	"GET /ws/tablets",
+	"GET /graphql",
+	"POST /graphql",
+	"GET /openapi.json",
+	"GET /docs",
+}
+
+func TestOpenAPI_EveryRouteDocumented(t *testing.T) {
+	handler := newHTTPHandler(NewServer())
+	doc := fetchOpenAPI(t, handler)
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("expected openapi 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	var documented []string
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(documented)
+	want := slices.Sorted(slices.Values(servedOperations))
+	if !slices.Equal(documented, want) {
+		t.Errorf("expected the spec to document\n%q\ngot\n%q", want, documented)
+	}
+
+	// Every served operation must reach a handler; a canceled context ends
+	// the event stream as soon as it starts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, op := range servedOperations {
+		method, path, _ := strings.Cut(op, " ")
+		req := httptest.NewRequestWithContext(ctx, method, strings.ReplaceAll(path, "{id}", "1"), nil)
+		req.Header.Set("Accept", problemContentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code == http.StatusNotFound && strings.Contains(rec.Body.String(), string(errCodeRouteNotFound)) ||
+			rec.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s is not routed: %d %s", op, rec.Code, rec.Body.String())
+		}
+	}
+
+	// And nothing else is.
+	for _, op := range []string{"DELETE /api/menu/1", "POST /api/menu", "PUT /api/v2/menu/1", "GET /metrics", "GET /admin"} {
+		method, url, _ := strings.Cut(op, " ")
+		req := httptest.NewRequest(method, url, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound && rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: expected an undocumented operation not to be routed, got %d", op, rec.Code)
+		}
+	}
+}
+
+func TestOpenAPI_DocumentedPathsAreServed(t *testing.T) {
+	server := NewServer()
+	handler := newHTTPHandler(server)
+	doc := fetchOpenAPI(t, handler)
+
+	for path, ops := range doc.Paths {
+		if path == "/api/menu/stream" || path == "/ws/tablets" {
+			continue // long-lived; covered by their own tests
+		}
+		for method := range ops {
+			url := strings.ReplaceAll(path, "{id}", "1")
+			req := httptest.NewRequest(strings.ToUpper(method), url, nil)
+			req.Header.Set("Accept", problemContentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code == http.StatusNotFound && strings.Contains(rec.Body.String(), string(errCodeRouteNotFound)) ||
+				rec.Code == http.StatusMethodNotAllowed {
+				t.Errorf("documented operation %s %s is not routed", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPI_OperationIDsUnique(t *testing.T) {
+	doc := newOpenAPIDocument(NewServer().routes())
+
+	seen := map[string]bool{}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			if op.OperationID == "" {
+				t.Errorf("%s %s has no operationId", method, path)
+			}
+			if seen[op.OperationID] {
+				t.Errorf("duplicate operationId %q", op.OperationID)
+			}
+			seen[op.OperationID] = true
+		}
+	}
+}
+
+func TestOpenAPI_MenuItemSchemaMatchesJSON(t *testing.T) {
+	doc := newOpenAPIDocument(NewServer().routes())
+
+	schema := doc.Components.Schemas["MenuItem"]
+	if schema == nil {
+		t.Fatal("MenuItem schema missing from components")
+	}
+
+	data, err := json.Marshal(MenuItem{})
+	if err != nil {
+		t.Fatalf("failed to marshal MenuItem: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("failed to unmarshal MenuItem: %v", err)
+	}
+
+	var want, got []string
+	for name := range fields {
+		want = append(want, name)
+	}
+	for name := range schema.Properties {
+		got = append(got, name)
+	}
+	slices.Sort(want)
+	slices.Sort(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("schema properties %v do not match JSON fields %v", got, want)
+	}
+
+	if p := schema.Properties["price"]; p == nil || p.Type != "number" {
+		t.Errorf("expected price to be a number, got %+v", p)
+	}
+	if p := schema.Properties["prep_time_minutes"]; p == nil || p.Type != "integer" {
+		t.Errorf("expected prep_time_minutes to be an integer, got %+v", p)
+	}
+}
+
+func TestOpenAPI_SchemaFollowsJSONTags(t *testing.T) {
+	type sample struct {
+		Name     string    `json:"name"`
+		Optional string    `json:"optional,omitempty"`
+		Skipped  string    `json:"-"`
+		Tags     []string  `json:"tags"`
+		Nested   *MenuItem `json:"nested,omitempty"`
+		hidden   string
+	}
+	_ = sample{}.hidden
+
+	doc := &openAPIDocument{Components: openAPIComponents{Schemas: map[string]*jsonSchema{}}}
+	doc.schemaFor(reflect.TypeOf(sample{}))
+	schema := doc.Components.Schemas["sample"]
+	if schema == nil {
+		t.Fatal("named struct was not added to components")
+	}
+
+	if _, ok := schema.Properties["Skipped"]; ok {
+		t.Error(`json:"-" field should be skipped`)
+	}
+	if _, ok := schema.Properties["hidden"]; ok {
+		t.Error("unexported field should be skipped")
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"name", "tags"}) {
+		t.Errorf("expected required [name tags], got %v", schema.Required)
+	}
+	if schema.Properties["tags"].Items.Type != "string" {
+		t.Errorf("expected tags items to be strings, got %+v", schema.Properties["tags"].Items)
+	}
+	if ref := schema.Properties["nested"].Ref; ref != "#/components/schemas/MenuItem" {
+		t.Errorf("expected nested to reference MenuItem, got %q", ref)
+	}
+}
+
+func TestDocsHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	rec := httptest.NewRecorder()
+	newHTTPHandler(NewServer()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "/openapi.json") {
+		t.Error("docs page does not load /openapi.json")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type OrderItem struct {
+	MenuItemID string  `json:"menu_item_id"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"`
+	Price      float64 `json:"price"`
+}
+
+type Order struct {
+	ID         string      `json:"id"`
+	Restaurant string      `json:"restaurant"`
+	Items      []OrderItem `json:"items"`
+	Total      float64     `json:"total"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// simulateOrders places a random order for an available menu item every
+// interval, standing in for the customer side of the order flow until ctx is
+// cancelled.
+func (s *Server) simulateOrders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			order, ok := s.randomOrder(ctx)
+			if !ok {
+				continue
+			}
+			if err := s.tablets.Dispatch(ctx, order); err != nil {
+				return
+			}
+			log.Debug().
+				Str("order_id", order.ID).
+				Str("restaurant", order.Restaurant).
+				Msg("Simulated order placed")
+		}
+	}
+}
+
+func (s *Server) randomOrder(ctx context.Context) (Order, bool) {
+	items, err := s.menu.List(ctx)
+	if err != nil {
+		return Order{}, false
+	}
+
+	var available []MenuItem
+	for _, item := range items {
+		if item.Available {
+			available = append(available, item)
+		}
+	}
+	if len(available) == 0 {
+		return Order{}, false
+	}
+
+	item := available[rand.Intn(len(available))]
+	quantity := 1 + rand.Intn(3)
+	return Order{
+		ID:         uuid.NewString(),
+		Restaurant: item.Restaurant,
+		Items:      []OrderItem{{MenuItemID: item.ID, Name: item.Name, Quantity: quantity, Price: item.Price}},
+		Total:      item.Price * float64(quantity),
+		CreatedAt:  time.Now(),
+	}, true
+}
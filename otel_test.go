@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	globalSpanRecorder     *tracetest.SpanRecorder
+	globalSpanRecorderOnce sync.Once
+)
+
+// installSpanRecorder routes the package tracer to a span recorder shared by
+// every test. The global provider can only be delegated once, so tests pick
+// out their own spans with spansInTrace.
+func installSpanRecorder() *tracetest.SpanRecorder {
+	globalSpanRecorderOnce.Do(func() {
+		globalSpanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(globalSpanRecorder)))
+	})
+	return globalSpanRecorder
+}
+
+func spansInTrace(recorder *tracetest.SpanRecorder, id trace.TraceID) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == id {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const problemContentType = "application/problem+json"
+
+// errorCode identifies an error for clients independently of its message.
+type errorCode string
+
+const (
+	errCodeValidationFailed  errorCode = "VALIDATION_FAILED"
+	errCodeInvalidRequest    errorCode = "INVALID_REQUEST"
+	errCodeBodyTooLarge      errorCode = "BODY_TOO_LARGE"
+	errCodeMethodNotAllowed  errorCode = "METHOD_NOT_ALLOWED"
+	errCodeRouteNotFound     errorCode = "ROUTE_NOT_FOUND"
+	errCodeMenuItemNotFound  errorCode = "MENU_ITEM_NOT_FOUND"
+	errCodeMenuUnavailable   errorCode = "MENU_UNAVAILABLE"
+	errCodeRateLimited       errorCode = "RATE_LIMITED"
+	errCodeOverloaded        errorCode = "OVERLOADED"
+	errCodeTimeout           errorCode = "TIMEOUT"
+	errCodeUnauthenticated   errorCode = "UNAUTHENTICATED"
+	errCodeForbidden         errorCode = "FORBIDDEN"
+	errCodeAuthNotConfigured errorCode = "AUTH_NOT_CONFIGURED"
+)
+
+// problemType is the RFC 7807 type URI for code. The URNs identify the error
+// kind and are not meant to be dereferenced.
+func (c errorCode) problemType() string {
+	return "urn:problem-type:menu-api:" + strings.ReplaceAll(strings.ToLower(string(c)), "_", "-")
+}
+
+// Problem is an RFC 7807 error body, written instead of ErrorResponse to
+// clients that accept application/problem+json.
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Instance   string      `json:"instance,omitempty"`
+	Code       errorCode   `json:"code"`
+	TraceID    string      `json:"trace_id,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+func newProblem(r *http.Request, status int, code errorCode, detail string, violations []Violation) Problem {
+	p := Problem{
+		Type:       code.problemType(),
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     detail,
+		Code:       code,
+		Violations: violations,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			p.TraceID = sc.TraceID().String()
+		}
+	}
+	return p
+}
+
+// acceptsProblem reports whether the request's Accept header asks for
+// application/problem+json. Everything else, including no Accept header,
+// keeps the original {error, message} body.
+func acceptsProblem(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != problemContentType {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// unmatchedHandler answers requests no route of mux matches with the same
+// error bodies as everything else instead of ServeMux's plain text: 405 with
+// an Allow header when the path is routed for other methods, 404 otherwise.
+// It must be registered as mux's "/" pattern.
+func unmatchedHandler(mux *http.ServeMux) http.HandlerFunc {
+	methods := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range methods {
+			probe := &http.Request{Method: method, Host: r.Host, URL: r.URL}
+			if _, pattern := mux.Handler(probe); pattern != "/" && pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed,
+				fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+			return
+		}
+		writeError(w, r, http.StatusNotFound, errCodeRouteNotFound, "No route for "+r.URL.Path)
+	}
+}
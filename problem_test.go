@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAcceptsProblem(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{"application/problem+json", true},
+		{"application/json, application/problem+json;q=0.9", true},
+		{"application/problem+json;q=0", false},
+		{"text/html, application/problem+json", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := acceptsProblem(req); got != tt.want {
+			t.Errorf("acceptsProblem(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWriteError_Problem(t *testing.T) {
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{1}})
+	req := httptest.NewRequest(http.MethodGet, "/api/menu/999", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), sc))
+	req.Header.Set("Accept", "application/problem+json")
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, http.StatusNotFound, errCodeMenuItemNotFound, "Menu item with ID '999' not found")
+
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("expected Content-Type %q, got %q", problemContentType, ct)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	want := Problem{
+		Type:     "urn:problem-type:menu-api:menu-item-not-found",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "Menu item with ID '999' not found",
+		Instance: "/api/menu/999",
+		Code:     errCodeMenuItemNotFound,
+		TraceID:  traceID.String(),
+	}
+	if p.Type != want.Type || p.Title != want.Title || p.Status != want.Status || p.Detail != want.Detail ||
+		p.Instance != want.Instance || p.Code != want.Code || p.TraceID != want.TraceID {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+}
+
+func TestWriteError_DefaultsToLegacyShape(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/menu/999", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, http.StatusNotFound, errCodeMenuItemNotFound, "not found")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", ct)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(body) != 2 || body["error"] != "Not Found" || body["message"] != "not found" {
+		t.Errorf("expected only error and message, got %v", body)
+	}
+}
+
+func TestProblem_UsedByHandlersAndMiddleware(t *testing.T) {
+	installSpanRecorder()
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		code   errorCode
+	}{
+		{"handler", "/api/menu/999", http.StatusNotFound, errCodeMenuItemNotFound},
+		{"validation", "/api/menu/stream?last_event_id=x", http.StatusBadRequest, errCodeValidationFailed},
+		{"unknown route", "/nope", http.StatusNotFound, errCodeRouteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept", problemContentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if p.Code != tt.code || p.Status != tt.status {
+				t.Errorf("expected code %s status %d, got %+v", tt.code, tt.status, p)
+			}
+			if p.TraceID == "" {
+				t.Error("expected trace_id from the request span")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// supportedProtocols lists the values accepted in server.protocols. HTTP/3
+// needs a QUIC listener alongside the TCP one and is not offered yet.
+var supportedProtocols = []string{"http1", "h2c", "h2"}
+
+// httpProtocols maps server.protocols onto the standard library's switches.
+func httpProtocols(names []string) *http.Protocols {
+	var p http.Protocols
+	p.SetHTTP1(slices.Contains(names, "http1"))
+	p.SetUnencryptedHTTP2(slices.Contains(names, "h2c"))
+	p.SetHTTP2(slices.Contains(names, "h2"))
+	return &p
+}
+
+// alpnProtocols returns the ALPN identifiers to advertise during the TLS
+// handshake, most preferred first.
+func alpnProtocols(names []string) []string {
+	var protos []string
+	if slices.Contains(names, "h2") {
+		protos = append(protos, "h2")
+	}
+	if slices.Contains(names, "http1") {
+		protos = append(protos, "http/1.1")
+	}
+	return protos
+}
+
+// negotiatedProtocol names the protocol a request arrived over, telling
+// cleartext HTTP/2 apart from HTTP/2 over TLS.
+func negotiatedProtocol(r *http.Request) string {
+	switch {
+	case r.ProtoMajor == 2 && r.TLS == nil:
+		return "h2c"
+	case r.ProtoMajor == 2:
+		return "h2"
+	case r.ProtoMajor == 3:
+		return "h3"
+	case r.ProtoAtLeast(1, 1):
+		return "http/1.1"
+	default:
+		return "http/1.0"
+	}
+}
+
+// protocolMiddleware records the negotiated protocol on the server span.
+func protocolMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.String("http.connection.protocol", negotiatedProtocol(r)),
+			attribute.Bool("http.connection.tls", r.TLS != nil),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// serveProtocols starts a server offering protocols that echoes the
+// negotiated protocol, and returns its address.
+func serveProtocols(t *testing.T, protocols []string, tlsConfig *tls.Config) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &http.Server{
+		Protocols: httpProtocols(protocols),
+		TLSConfig: tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, negotiatedProtocol(r))
+		}),
+	}
+	go func() {
+		if tlsConfig != nil {
+			_ = srv.ServeTLS(ln, "", "")
+			return
+		}
+		_ = srv.Serve(ln)
+	}()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return ln.Addr().String()
+}
+
+func fetchProtocol(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// =============================================================================
+// Protocol Negotiation Tests
+// =============================================================================
+
+func TestProtocols_H2C(t *testing.T) {
+	addr := serveProtocols(t, []string{"http1", "h2c"}, nil)
+
+	var h2c http.Protocols
+	h2c.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &h2c}}
+
+	if got := fetchProtocol(t, client, "http://"+addr+"/"); got != "h2c" {
+		t.Errorf("expected h2c, got %q", got)
+	}
+	if got := fetchProtocol(t, http.DefaultClient, "http://"+addr+"/"); got != "http/1.1" {
+		t.Errorf("expected HTTP/1.1 clients to keep working, got %q", got)
+	}
+}
+
+func TestProtocols_H2CDisabledByDefault(t *testing.T) {
+	addr := serveProtocols(t, defaultConfig().Server.Protocols, nil)
+
+	var h2c http.Protocols
+	h2c.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &h2c}}
+
+	if _, err := client.Get("http://" + addr + "/"); err == nil {
+		t.Error("expected prior-knowledge h2c to be refused by default")
+	}
+}
+
+func TestProtocols_TLS(t *testing.T) {
+	f := newTLSFixture(t, "none")
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(f.ca.pem)
+
+	testCases := []struct {
+		name      string
+		protocols []string
+		expected  string
+	}{
+		{"h2 preferred", []string{"http1", "h2"}, "h2"},
+		{"http1 only", []string{"http1"}, "http/1.1"},
+		{"h2 only", []string{"h2"}, "h2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := serveProtocols(t, tc.protocols, r.serverConfig(alpnProtocols(tc.protocols), tls.NoClientCert))
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: pool},
+				ForceAttemptHTTP2: true,
+			}}
+
+			if got := fetchProtocol(t, client, "https://"+addr+"/"); got != tc.expected {
+				t.Errorf("expected %s, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNegotiatedProtocol(t *testing.T) {
+	testCases := []struct {
+		major, minor int
+		tls          bool
+		expected     string
+	}{
+		{1, 0, false, "http/1.0"},
+		{1, 1, false, "http/1.1"},
+		{1, 1, true, "http/1.1"},
+		{2, 0, false, "h2c"},
+		{2, 0, true, "h2"},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.ProtoMajor, r.ProtoMinor = tc.major, tc.minor
+		if !tc.tls {
+			r.TLS = nil
+		} else {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		if got := negotiatedProtocol(r); got != tc.expected {
+			t.Errorf("HTTP/%d.%d tls=%v: expected %q, got %q", tc.major, tc.minor, tc.tls, tc.expected, got)
+		}
+	}
+}
+
+func TestProtocolMiddleware_RecordsOnSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	handler := protocolMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	r := httptest.NewRequest(http.MethodGet, "/api/menu", nil).WithContext(ctx)
+	r.ProtoMajor, r.ProtoMinor = 2, 0
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	span.End()
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range recorder.Ended()[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs["http.connection.protocol"].AsString(); got != "h2c" {
+		t.Errorf("expected protocol h2c on span, got %q", got)
+	}
+	if attrs["http.connection.tls"].AsBool() {
+		t.Error("expected tls=false on span")
+	}
+}
+
+func TestProtocolsValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		protocols []string
+		contains  string
+	}{
+		{"empty", nil, "at least one protocol"},
+		{"unknown", []string{"http1", "spdy"}, `unknown protocol "spdy"`},
+		{"h2 without TLS", []string{"h2"}, "h2 requires server.tls"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Server.Protocols = tc.protocols
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("expected error containing %q, got %v", tc.contains, err)
+			}
+		})
+	}
+}
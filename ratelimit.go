@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// What a rate limit counts requests by.
+const (
+	rateLimitByIP     = "ip"
+	rateLimitByAPIKey = "api_key"
+	rateLimitByRoute  = "route"
+)
+
+// apiKeyHeader carries the API key that authenticates a request and that
+// api_key limits count by.
+const apiKeyHeader = "X-API-Key"
+
+// rateLimitSweepInterval is how often buckets that have refilled are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimit is a parsed limit such as "20/s burst=40 by=api_key".
+type rateLimit struct {
+	rate  float64 // tokens added per second
+	burst int     // bucket size
+	by    string
+}
+
+// parseRateLimit parses a limit written as "<requests>/<s|m|h>" followed by
+// optional "burst=<n>" and "by=ip|api_key|route". Burst defaults to the
+// requests per period and by to ip. "off" returns nil, meaning no limit.
+func parseRateLimit(spec string) (*rateLimit, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty limit")
+	}
+	if len(fields) == 1 && fields[0] == "off" {
+		return nil, nil
+	}
+
+	count, unit, ok := strings.Cut(fields[0], "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return nil, fmt.Errorf("%q: expected <requests>/<s|m|h> with at least 1 request", fields[0])
+	}
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return nil, fmt.Errorf("%q: unknown period %q, expected s, m or h", fields[0], unit)
+	}
+
+	limit := &rateLimit{rate: float64(n) / period.Seconds(), burst: n, by: rateLimitByIP}
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "burst":
+			burst, err := strconv.Atoi(value)
+			if err != nil || burst < 1 {
+				return nil, fmt.Errorf("%q: burst must be a positive integer", field)
+			}
+			limit.burst = burst
+		case "by":
+			switch value {
+			case rateLimitByIP, rateLimitByAPIKey, rateLimitByRoute:
+				limit.by = value
+			default:
+				return nil, fmt.Errorf("%q: expected by=ip, by=api_key or by=route", field)
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %q", field)
+		}
+	}
+	return limit, nil
+}
+
+// rateLimitPolicy is a RateLimitConfig parsed for use on every request.
+type rateLimitPolicy struct {
+	fallback *rateLimit
+	routes   map[string]*rateLimit
+	trusted  []netip.Prefix
+}
+
+func newRateLimitPolicy(cfg RateLimitConfig) (*rateLimitPolicy, error) {
+	p := &rateLimitPolicy{routes: map[string]*rateLimit{}}
+
+	var err error
+	if cfg.Default != "" {
+		if p.fallback, err = parseRateLimit(cfg.Default); err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+	}
+	for pattern, spec := range cfg.Routes {
+		if !isRoutePattern(pattern) {
+			return nil, fmt.Errorf("routes: %q is not a route pattern like \"GET /api/menu\"", pattern)
+		}
+		if p.routes[pattern], err = parseRateLimit(spec); err != nil {
+			return nil, fmt.Errorf("routes: %s: %w", pattern, err)
+		}
+	}
+	for _, cidr := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("trusted_proxies: %w", err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.trusted = append(p.trusted, prefix.Masked())
+	}
+	return p, nil
+}
+
+// limitFor returns the limit for the route and the policy name reported in
+// RateLimit headers, or nil if it is unlimited. A limit set for an
+// unversioned route also covers its /api/v1 and /api/v2 aliases.
+func (p *rateLimitPolicy) limitFor(rt route) (*rateLimit, string) {
+	if limit, ok := p.routes[rt.pattern]; ok {
+		return limit, rt.path()
+	}
+	canonical := rt.canonicalPattern()
+	if limit, ok := p.routes[canonical]; ok {
+		_, path, _ := strings.Cut(canonical, " ")
+		return limit, path
+	}
+	return p.fallback, "default"
+}
+
+func (p *rateLimitPolicy) trusts(addr netip.Addr) bool {
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent r. When the peer is a
+// trusted proxy, X-Forwarded-For is followed from the right past every
+// trusted hop, so a client cannot pick its address by sending the header
+// itself.
+func (p *rateLimitPolicy) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && p.trusts(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr.String()
+}
+
+// tokenBucket holds up to burst tokens and refills at the limit's rate.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled if left alone
+}
+
+// rateLimitResult is the outcome of taking a token, for the response headers.
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, when not allowed
+}
+
+// rateLimiter applies token-bucket limits to routes, keeping one bucket per
+// route and client.
+type rateLimiter struct {
+	policy  atomic.Pointer[rateLimitPolicy]
+	limited metric.Int64Counter
+	now     func() time.Time
+	// apiKeyID resolves an API key secret to the ID of the configured key;
+	// nil until the authenticator is wired in.
+	apiKeyID func(secret string) (string, bool)
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter(cfg RateLimitConfig) (*rateLimiter, error) {
+	policy, err := newRateLimitPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	limited, err := meter.Int64Counter("http.server.rate_limited",
+		metric.WithDescription("HTTP requests rejected by a rate limit."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("rate limit counter: %w", err)
+	}
+
+	l := &rateLimiter{limited: limited, now: time.Now, buckets: map[string]*tokenBucket{}}
+	l.policy.Store(policy)
+	return l, nil
+}
+
+// configure applies cfg, keeping the current limits if it is invalid.
+// Buckets carry over, so reloading does not hand every client a fresh burst.
+func (l *rateLimiter) configure(cfg RateLimitConfig) {
+	policy, err := newRateLimitPolicy(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid rate limits, keeping the previous ones")
+		return
+	}
+	l.policy.Store(policy)
+}
+
+// take removes a token from the bucket for key if it has one.
+func (l *rateLimiter) take(key string, limit *rateLimit) rateLimitResult {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= rateLimitSweepInterval {
+		for k, b := range l.buckets {
+			if !now.Before(b.full) {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(limit.burst), b.tokens+now.Sub(b.updated).Seconds()*limit.rate)
+	b.updated = now
+
+	res := rateLimitResult{allowed: b.tokens >= 1}
+	if res.allowed {
+		b.tokens--
+	} else {
+		res.retryAfter = seconds((1 - b.tokens) / limit.rate)
+	}
+	res.reset = seconds((float64(limit.burst) - b.tokens) / limit.rate)
+	res.remaining = int(b.tokens)
+	b.full = now.Add(res.reset)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds rounds d up to whole seconds for headers that carry seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// limit wraps the handler of rt in its rate limit. Every limited route
+// answers with RateLimit-Policy and RateLimit headers as drafted in
+// draft-ietf-httpapi-ratelimit-headers; rejected requests get a 429 with
+// Retry-After, and are counted, traced and logged.
+func (l *rateLimiter) limit(rt route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := l.policy.Load()
+		limit, name := policy.limitFor(rt)
+		if limit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Versioned aliases of a route share its buckets, so switching
+		// between /api/menu, /api/v1/menu and /api/v2/menu gains nothing.
+		canonical := rt.canonicalPattern()
+		client := canonical
+		switch limit.by {
+		case rateLimitByIP:
+			client = policy.clientIP(r)
+		case rateLimitByAPIKey:
+			client = l.apiKeyBucket(r, policy)
+		}
+		res := l.take(canonical+"|"+client, limit)
+
+		h := w.Header()
+		window := ceilSeconds(seconds(float64(limit.burst) / limit.rate))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%q;q=%d;w=%d", name, limit.burst, window))
+		h.Set("RateLimit", fmt.Sprintf("%q;r=%d;t=%d", name, res.remaining, ceilSeconds(res.reset)))
+		if res.allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		retryAfter := max(ceilSeconds(res.retryAfter), 1)
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+		l.limited.Add(r.Context(), 1, metric.WithAttributes(
+			attribute.String("http.route", rt.path()),
+			attribute.String("http.request.method", rt.method()),
+			attribute.String("rate_limit.by", limit.by),
+		))
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.Bool("rate_limit.limited", true),
+			attribute.String("rate_limit.by", limit.by),
+		)
+		addLogField(r.Context(), "rate_limited", limit.by)
+		writeError(w, r, http.StatusTooManyRequests, errCodeRateLimited,
+			fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter))
+	})
+}
+
+// apiKeyBucket names the bucket of the configured key the request presents,
+// by its ID, falling back to the client's address for requests without a
+// known key. Unknown keys share the address's bucket, so sending a new key
+// with every request neither escapes the limit nor adds buckets.
+func (l *rateLimiter) apiKeyBucket(r *http.Request, policy *rateLimitPolicy) string {
+	if secret := r.Header.Get(apiKeyHeader); secret != "" && l.apiKeyID != nil {
+		if id, ok := l.apiKeyID(secret); ok {
+			return "key:" + id
+		}
+	}
+	return "ip:" + policy.clientIP(r)
+}
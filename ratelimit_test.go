@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newRateLimitedServer returns a server whose rate limiter uses cfg and a
+// clock the test moves by hand.
+func newRateLimitedServer(t *testing.T, cfg RateLimitConfig) (*Server, *time.Time) {
+	t.Helper()
+
+	server := newTestServerWithoutFaults()
+	policy, err := newRateLimitPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.rateLimiter.policy.Store(policy)
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	server.rateLimiter.now = func() time.Time { return now }
+	return server, &now
+}
+
+func serveFrom(handler http.Handler, url, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.RemoteAddr = remoteAddr
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestParseRateLimit(t *testing.T) {
+	valid := map[string]rateLimit{
+		"10/s":                    {rate: 10, burst: 10, by: rateLimitByIP},
+		"60/m burst=5":            {rate: 1, burst: 5, by: rateLimitByIP},
+		"3600/h by=api_key":       {rate: 1, burst: 3600, by: rateLimitByAPIKey},
+		" 2/s  burst=4  by=route": {rate: 2, burst: 4, by: rateLimitByRoute},
+	}
+	for spec, want := range valid {
+		got, err := parseRateLimit(spec)
+		if err != nil {
+			t.Errorf("parseRateLimit(%q) returned error: %v", spec, err)
+			continue
+		}
+		if *got != want {
+			t.Errorf("parseRateLimit(%q) = %+v, want %+v", spec, *got, want)
+		}
+	}
+
+	if limit, err := parseRateLimit("off"); limit != nil || err != nil {
+		t.Errorf("expected off to disable the limit, got %+v, %v", limit, err)
+	}
+
+	for _, spec := range []string{"", "10", "0/s", "10/d", "x/s", "10/s burst=0", "10/s by=user", "10/s fast"} {
+		if _, err := parseRateLimit(spec); err == nil {
+			t.Errorf("expected parseRateLimit(%q) to fail", spec)
+		}
+	}
+}
+
+func TestRateLimitPolicy_Validation(t *testing.T) {
+	for _, cfg := range []RateLimitConfig{
+		{Default: "fast"},
+		{Routes: map[string]string{"/api/menu": "10/s"}},
+		{Routes: map[string]string{"GET /api/menu": "10"}},
+		{TrustedProxies: []string{"10.0.0.0/33"}},
+	} {
+		if _, err := newRateLimitPolicy(cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+
+	cfg := defaultConfig()
+	cfg.RateLimit.Routes = map[string]string{"GET /api/menu": "every second"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "rate_limit: routes: GET /api/menu") {
+		t.Errorf("expected Validate to name the bad route, got %v", err)
+	}
+}
+
+func TestRateLimitPolicy_ClientIP(t *testing.T) {
+	policy, err := newRateLimitPolicy(RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer cannot forward", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted ingress", "10.1.2.3:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entries are skipped", "10.1.2.3:5000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of proxies", "10.1.2.3:5000", []string{"198.51.100.1, 192.168.1.1", "10.9.9.9"}, "198.51.100.1"},
+		{"only proxies", "10.1.2.3:5000", []string{"10.4.4.4"}, "10.4.4.4"},
+		{"garbage stops the walk", "10.1.2.3:5000", []string{"198.51.100.1, bogus"}, "10.1.2.3"},
+		{"ipv4-mapped peer", "[::ffff:10.1.2.3]:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"ipv6 client", "10.1.2.3:5000", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := policy.clientIP(req); got != tc.want {
+				t.Errorf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	limit := &rateLimit{rate: 2, burst: 3}
+
+	for i := range 3 {
+		if res := limiter.take("k", limit); !res.allowed || res.remaining != 2-i {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i, 2-i, res)
+		}
+	}
+	res := limiter.take("k", limit)
+	if res.allowed || res.retryAfter != 500*time.Millisecond || res.reset != 1500*time.Millisecond {
+		t.Errorf("expected a rejection with a token due in 500ms, got %+v", res)
+	}
+	if other := limiter.take("other", limit); !other.allowed {
+		t.Error("expected buckets to be independent")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res := limiter.take("k", limit); !res.allowed {
+		t.Errorf("expected a token after refilling, got %+v", res)
+	}
+
+	now = now.Add(rateLimitSweepInterval)
+	limiter.take("k", limit)
+	if _, ok := limiter.buckets["other"]; ok {
+		t.Error("expected a refilled bucket to be swept")
+	}
+}
+
+func TestRateLimit_RejectsWith429(t *testing.T) {
+	server, now := newRateLimitedServer(t, RateLimitConfig{
+		Default: "100/s",
+		Routes:  map[string]string{"GET /api/menu/{id}": "2/m"},
+	})
+	handler := newHTTPHandler(server)
+
+	for range 2 {
+		rec := serveFrom(handler, "/api/menu/1", "203.0.113.7:5000", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	}
+
+	rec := serveFrom(handler, "/api/menu/1", "203.0.113.7:5000", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != `"/api/menu/{id}";q=2;w=60` {
+		t.Errorf("unexpected RateLimit-Policy %q", got)
+	}
+	if got := rec.Header().Get("RateLimit"); got != `"/api/menu/{id}";r=0;t=60` {
+		t.Errorf("unexpected RateLimit %q", got)
+	}
+	var body ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != "Too Many Requests" {
+		t.Errorf("expected an error body, got %q", rec.Body.String())
+	}
+
+	if rec := serveFrom(handler, "/api/menu/1", "203.0.113.8:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected another client to have its own bucket, got %d", rec.Code)
+	}
+	rec = serveFrom(handler, "/api/menu", "203.0.113.7:5000", nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected other routes to keep their own limit, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != `"default";q=100;w=1` {
+		t.Errorf("unexpected default RateLimit-Policy %q", got)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if rec := serveFrom(handler, "/api/menu/1", "203.0.113.7:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected a token after Retry-After, got %d", rec.Code)
+	}
+}
+
+func TestRateLimit_KeyedByAPIKeyAndRoute(t *testing.T) {
+	server, _ := newRateLimitedServer(t, RateLimitConfig{
+		Routes: map[string]string{
+			"GET /api/menu":      "1/m by=api_key",
+			"GET /api/menu/{id}": "1/m by=route",
+		},
+	})
+	keys, err := loadAPIKeys(AuthConfig{
+		APIKeys: map[string]string{
+			"alpha": hashedAPIKey("alpha-secret", "menu:read"),
+			"beta":  hashedAPIKey("beta-secret", "menu:read"),
+		},
+		AnonymousScopes: []string{"menu:read"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.auth.keys.Store(keys)
+	handler := newHTTPHandler(server)
+	key := func(k string) http.Header { return http.Header{apiKeyHeader: {k}} }
+
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.7:5000", key("alpha-secret")); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.8:5000", key("alpha-secret")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the key's bucket to follow it across addresses, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.7:5000", key("beta-secret")); rec.Code != http.StatusOK {
+		t.Errorf("expected another key to have its own bucket, got %d", rec.Code)
+	}
+
+	// Unknown keys count against the client's address, so rotating them
+	// neither escapes the limit nor grows the bucket table.
+	buckets := len(server.rateLimiter.buckets)
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.9:5000", key("guess-1")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown key to be refused, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.9:5000", key("guess-2")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected unknown keys to share the address's bucket, got %d", rec.Code)
+	}
+	if got := len(server.rateLimiter.buckets); got != buckets+1 {
+		t.Errorf("expected one bucket for the address, got %d new", got-buckets)
+	}
+
+	if rec := serveFrom(handler, "/api/menu/1", "203.0.113.7:5000", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu/2", "203.0.113.8:5000", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a route limit to be shared by every client, got %d", rec.Code)
+	}
+
+	if rec := serveFrom(handler, "/health", "203.0.113.7:5000", nil); rec.Header().Get("RateLimit") != "" {
+		t.Error("expected no RateLimit headers on unlimited routes")
+	}
+}
+
+func TestRateLimit_HonorsTrustedForwardedFor(t *testing.T) {
+	server, _ := newRateLimitedServer(t, RateLimitConfig{
+		Default:        "1/m",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+	handler := newHTTPHandler(server)
+	forwarded := func(ip string) http.Header { return http.Header{"X-Forwarded-For": {ip}} }
+
+	serveFrom(handler, "/api/menu", "10.0.0.5:5000", forwarded("198.51.100.1"))
+	if rec := serveFrom(handler, "/api/menu", "10.0.0.6:5000", forwarded("198.51.100.2")); rec.Code != http.StatusOK {
+		t.Errorf("expected clients behind the ingress to be told apart, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "10.0.0.6:5000", forwarded("198.51.100.1")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the forwarded client to be limited, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.7:5000", forwarded("198.51.100.3")); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if rec := serveFrom(handler, "/api/menu", "203.0.113.7:5000", forwarded("198.51.100.4")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected an untrusted peer not to escape its limit via X-Forwarded-For, got %d", rec.Code)
+	}
+}
+
+func TestRateLimit_CountsAndLogsRejections(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	server, _ := newRateLimitedServer(t, RateLimitConfig{Default: "1/m"})
+	counter, err := provider.Meter("test").Int64Counter("http.server.rate_limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.rateLimiter.limited = counter
+
+	var logs bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&logs)
+
+	handler := loggingMiddleware(newHTTPHandler(server))
+	serveFrom(handler, "/api/menu", "203.0.113.7:5000", nil)
+	serveFrom(handler, "/api/menu", "203.0.113.7:5000", nil)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	var last map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last["status"] != float64(http.StatusTooManyRequests) || last["rate_limited"] != rateLimitByIP {
+		t.Errorf("expected the rejection to be logged with rate_limited=ip, got %v", last)
+	}
+	if strings.Contains(lines[0], "rate_limited") {
+		t.Errorf("expected allowed requests to log no rate_limited field, got %s", lines[0])
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	var got int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || m.Name != "http.server.rate_limited" {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				route, _ := dp.Attributes.Value(attribute.Key("http.route"))
+				by, _ := dp.Attributes.Value(attribute.Key("rate_limit.by"))
+				if route.AsString() == "/api/menu" && by.AsString() == rateLimitByIP {
+					got += dp.Value
+				}
+			}
+		}
+	}
+	if got != 1 {
+		t.Errorf("expected one rejection counted for /api/menu, got %d", got)
+	}
+}
+
+func TestRateLimit_VersionedAliasesShareBuckets(t *testing.T) {
+	server, _ := newRateLimitedServer(t, RateLimitConfig{
+		Default: "100/s",
+		Routes:  map[string]string{"GET /api/menu/{id}": "2/m"},
+	})
+	handler := newHTTPHandler(server)
+
+	for _, url := range []string{"/api/menu/1", "/api/v1/menu/1"} {
+		rec := serveFrom(handler, url, "203.0.113.7:5000", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", url, rec.Code)
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != `"/api/menu/{id}";q=2;w=60` {
+			t.Errorf("%s: unexpected RateLimit-Policy %q", url, got)
+		}
+	}
+	if rec := serveFrom(handler, "/api/v2/menu/1", "203.0.113.7:5000", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected /api/v2 to share the bucket of its aliases, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const filePollInterval = 5 * time.Second
+
+// configChange describes one setting that differs between two configs.
+type configChange struct {
+	Path       string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+// diffConfig lists every leaf setting that differs between old and new, with
+// secret values redacted.
+func diffConfig(old, new Config) []configChange {
+	oldFields := map[string]configField{}
+	walkConfig(&old, func(f configField) { oldFields[f.path] = f })
+
+	var changes []configChange
+	walkConfig(&new, func(f configField) {
+		prev := oldFields[f.path]
+		if reflect.DeepEqual(prev.value.Interface(), f.value.Interface()) {
+			return
+		}
+		change := configChange{
+			Path:       f.path,
+			Old:        fmt.Sprint(prev.value.Interface()),
+			New:        fmt.Sprint(f.value.Interface()),
+			Reloadable: f.reloadable,
+		}
+		if f.secret {
+			change.Old, change.New = redacted, redacted
+		}
+		changes = append(changes, change)
+	})
+	return changes
+}
+
+// configReloader owns the live configuration. Settings tagged reload:"true"
+// are swapped in atomically on reload; changes to anything else are logged
+// and ignored until the next restart.
+type configReloader struct {
+	load     func() (Config, error)
+	current  atomic.Pointer[Config]
+	mu       sync.Mutex
+	onReload []func(Config)
+}
+
+func newConfigReloader(initial Config, load func() (Config, error)) *configReloader {
+	r := &configReloader{load: load}
+	r.current.Store(&initial)
+	return r
+}
+
+func (r *configReloader) Current() Config {
+	return *r.current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after every
+// reload that changes at least one reloadable setting.
+func (r *configReloader) OnReload(fn func(Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+// Reload re-resolves the configuration. An invalid configuration is rejected
+// and the previous one stays in effect. So is one whose reloadable settings
+// are invalid alongside the settings kept until the next restart, such as
+// route timeouts raised past the current write timeout.
+func (r *configReloader) Reload(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		log.Error().Err(err).Str("reason", reason).Msg("Rejected configuration reload, keeping previous configuration")
+		return err
+	}
+
+	old := r.Current()
+	candidate := old
+	nextFields := map[string]configField{}
+	walkConfig(&next, func(f configField) { nextFields[f.path] = f })
+
+	var applied []configChange
+	for _, change := range diffConfig(old, next) {
+		if !change.Reloadable {
+			log.Warn().
+				Str("reason", reason).
+				Str("setting", change.Path).
+				Str("old", change.Old).
+				Str("new", change.New).
+				Msg("Setting cannot be reloaded, restart to apply")
+			continue
+		}
+		walkConfig(&candidate, func(f configField) {
+			if f.path == change.Path {
+				f.value.Set(nextFields[f.path].value)
+			}
+		})
+		applied = append(applied, change)
+	}
+
+	if len(applied) == 0 {
+		log.Debug().Str("reason", reason).Msg("Configuration reloaded, nothing to apply")
+		return nil
+	}
+	if err := candidate.Validate(); err != nil {
+		log.Error().Err(err).Str("reason", reason).Msg("Rejected configuration reload, it conflicts with settings that need a restart; keeping previous configuration")
+		return err
+	}
+
+	r.current.Store(&candidate)
+	for _, change := range applied {
+		log.Info().
+			Str("reason", reason).
+			Str("setting", change.Path).
+			Str("old", change.Old).
+			Str("new", change.New).
+			Msg("Configuration setting changed")
+	}
+	for _, fn := range r.onReload {
+		fn(candidate)
+	}
+	log.Info().Str("reason", reason).Int("changed", len(applied)).Msg("Configuration reloaded")
+	return nil
+}
+
+// watchSignals reloads on every SIGHUP until ctx is cancelled.
+func (r *configReloader) watchSignals(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = r.Reload("SIGHUP")
+		}
+	}
+}
+
+// watchFile reloads whenever the config file at path changes.
+func (r *configReloader) watchFile(ctx context.Context, path string, interval time.Duration) {
+	watchFiles(ctx, interval, []string{path}, func() {
+		_ = r.Reload("file changed: " + path)
+	})
+}
+
+// watchFiles polls paths and calls onChange whenever their combined content
+// changes, until ctx is cancelled. Polling content rather than watching inodes
+// copes with ConfigMap and Secret volumes, which update by swapping a symlink
+// to a new directory. The first poll always fires, covering edits made between
+// the files being loaded and the watcher starting. Unreadable files are
+// skipped so a half-written update is retried on the next poll.
+func watchFiles(ctx context.Context, interval time.Duration, paths []string, onChange func()) {
+	sum := func() []byte {
+		h := sha256.New()
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			h.Write(data)
+		}
+		return h.Sum(nil)
+	}
+
+	var last []byte
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := sum()
+			if current == nil || bytes.Equal(current, last) {
+				continue
+			}
+			last = current
+			onChange()
+		}
+	}
+}
+
+func applyLogLevel(cfg Config) {
+	level, _ := zerolog.ParseLevel(cfg.Log.Level)
+	zerolog.SetGlobalLevel(level)
+}
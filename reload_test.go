@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// diffConfig Tests
+// =============================================================================
+
+func TestDiffConfig(t *testing.T) {
+	old := defaultConfig()
+	next := defaultConfig()
+	next.Log.Level = "debug"
+	next.Server.Addr = ":9090"
+	next.Telemetry.OTLPHeaders = map[string]string{"authorization": "Bearer s3cr3t"}
+
+	changes := diffConfig(old, next)
+
+	byPath := map[string]configChange{}
+	for _, c := range changes {
+		byPath[c.Path] = c
+	}
+	if len(byPath) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+
+	if c := byPath["log.level"]; c.Old != "info" || c.New != "debug" || !c.Reloadable {
+		t.Errorf("unexpected log.level change: %+v", c)
+	}
+	if c := byPath["server.addr"]; c.Reloadable {
+		t.Errorf("expected server.addr not to be reloadable: %+v", c)
+	}
+	if c := byPath["telemetry.otlp_headers"]; c.New != redacted {
+		t.Errorf("expected secret change to be redacted, got %+v", c)
+	}
+}
+
+func TestDiffConfig_NoChanges(t *testing.T) {
+	if changes := diffConfig(defaultConfig(), defaultConfig()); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
+
+// =============================================================================
+// configReloader Tests
+// =============================================================================
+
+func TestConfigReloader_AppliesReloadableSettings(t *testing.T) {
+	next := defaultConfig()
+	next.Log.Level = "warn"
+	next.Faults.MenuErrorRate = 0.5
+	next.Server.Addr = ":9090"
+
+	r := newConfigReloader(defaultConfig(), func() (Config, error) { return next, nil })
+
+	var notified []Config
+	r.OnReload(func(c Config) { notified = append(notified, c) })
+
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+
+	current := r.Current()
+	if current.Log.Level != "warn" {
+		t.Errorf("expected log level 'warn', got %q", current.Log.Level)
+	}
+	if current.Faults.MenuErrorRate != 0.5 {
+		t.Errorf("expected menu error rate 0.5, got %v", current.Faults.MenuErrorRate)
+	}
+	if current.Server.Addr != ":8080" {
+		t.Errorf("expected non-reloadable addr to keep ':8080', got %q", current.Server.Addr)
+	}
+	if len(notified) != 1 || notified[0].Faults.MenuErrorRate != 0.5 {
+		t.Errorf("expected one notification with the new config, got %+v", notified)
+	}
+}
+
+func TestConfigReloader_RejectsInvalidConfig(t *testing.T) {
+	r := newConfigReloader(defaultConfig(), func() (Config, error) {
+		return Config{}, errors.New("faults.menu_error_rate: must be between 0 and 1")
+	})
+
+	called := false
+	r.OnReload(func(Config) { called = true })
+
+	if err := r.Reload("test"); err == nil {
+		t.Error("expected Reload to return the load error")
+	}
+	if called {
+		t.Error("expected no notification for a rejected config")
+	}
+	if r.Current().Faults.MenuErrorRate != defaultConfig().Faults.MenuErrorRate {
+		t.Error("expected previous config to stay in effect")
+	}
+}
+
+func TestConfigReloader_RejectsConflictWithRestartOnlySettings(t *testing.T) {
+	// The route budget is reloadable but the write timeout it must stay
+	// under is not, so the new budget is checked against the old timeout.
+	next := defaultConfig()
+	next.Server.WriteTimeout = time.Minute
+	next.Timeouts.Default = 30 * time.Second
+
+	r := newConfigReloader(defaultConfig(), func() (Config, error) { return next, nil })
+
+	called := false
+	r.OnReload(func(Config) { called = true })
+
+	if err := r.Reload("test"); err == nil || !strings.Contains(err.Error(), "server.write_timeout") {
+		t.Errorf("expected the merged config to be rejected, got %v", err)
+	}
+	if called {
+		t.Error("expected no notification for a rejected config")
+	}
+	if got := r.Current().Timeouts.Default; got != defaultConfig().Timeouts.Default {
+		t.Errorf("expected the previous budget to stay in effect, got %s", got)
+	}
+}
+
+func TestConfigReloader_SkipsNotificationWithoutReloadableChanges(t *testing.T) {
+	next := defaultConfig()
+	next.Server.Addr = ":9090"
+
+	r := newConfigReloader(defaultConfig(), func() (Config, error) { return next, nil })
+
+	called := false
+	r.OnReload(func(Config) { called = true })
+
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if called {
+		t.Error("expected no notification when only non-reloadable settings change")
+	}
+}
+
+func TestConfigReloader_WatchFile(t *testing.T) {
+	path := writeConfigFile(t, "faults:\n  menu_error_rate: 0.1\n")
+	load := func() (Config, error) {
+		return loadConfig("test", []string{"-config", path}, envMap(nil))
+	}
+
+	initial, err := load()
+	if err != nil {
+		t.Fatalf("initial load failed: %v", err)
+	}
+	r := newConfigReloader(initial, load)
+
+	reloaded := make(chan Config, 1)
+	r.OnReload(func(c Config) { reloaded <- c })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watchFile(ctx, path, 10*time.Millisecond)
+
+	if err := os.WriteFile(path, []byte("faults:\n  menu_error_rate: 0.9\n"), 0o600); err != nil {
+		t.Fatalf("failed to update config file: %v", err)
+	}
+
+	select {
+	case c := <-reloaded:
+		if c.Faults.MenuErrorRate != 0.9 {
+			t.Errorf("expected menu error rate 0.9, got %v", c.Faults.MenuErrorRate)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config file change was not picked up")
+	}
+}
+
+func TestServer_FaultRateFollowsConfig(t *testing.T) {
+	server := NewServer()
+
+	testCases := []struct {
+		rate   float64
+		status int
+	}{
+		{1, http.StatusInternalServerError},
+		{0, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		server.faults.Store(&FaultConfig{MenuErrorRate: tc.rate})
+
+		for i := 0; i < 10; i++ {
+			rec := httptest.NewRecorder()
+			server.menuHandler(rec, httptest.NewRequest(http.MethodGet, "/api/menu", nil))
+
+			if rec.Code != tc.status {
+				t.Fatalf("rate %v: expected status %d, got %d", tc.rate, tc.status, rec.Code)
+			}
+		}
+	}
+}
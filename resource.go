@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+const serviceName = "friendly-octo-guacamole"
+
+// Environment variables populated by the Helm chart through the downward API.
+const (
+	envPodName        = "K8S_POD_NAME"
+	envPodUID         = "K8S_POD_UID"
+	envNamespaceName  = "K8S_NAMESPACE_NAME"
+	envNodeName       = "K8S_NODE_NAME"
+	envContainerName  = "K8S_CONTAINER_NAME"
+	envDeploymentName = "K8S_DEPLOYMENT_NAME"
+	envEnvironment    = "DEPLOYMENT_ENVIRONMENT"
+)
+
+// serviceDetector provides the service.* attributes owned by this binary.
+type serviceDetector struct{}
+
+func (serviceDetector) Detect(context.Context) (*resource.Resource, error) {
+	instanceID := os.Getenv(envPodUID)
+	if instanceID == "" {
+		instanceID = uuid.NewString()
+	}
+
+	build := currentBuildInfo()
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(build.Version),
+		semconv.ServiceInstanceID(instanceID),
+		semconv.VCSRefHeadRevision(build.Commit),
+	}
+	if env := os.Getenv(envEnvironment); env != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentName(env))
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// kubernetesDetector maps downward-API environment variables to k8s.*
+// attributes. Outside a cluster none of them are set and it detects nothing.
+type kubernetesDetector struct{}
+
+func (kubernetesDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for env, attr := range map[string]func(string) attribute.KeyValue{
+		envPodName:        semconv.K8SPodName,
+		envPodUID:         semconv.K8SPodUID,
+		envNamespaceName:  semconv.K8SNamespaceName,
+		envNodeName:       semconv.K8SNodeName,
+		envContainerName:  semconv.K8SContainerName,
+		envDeploymentName: semconv.K8SDeploymentName,
+	} {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, attr(v))
+		}
+	}
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// newResource describes this process for every signal it exports. Detectors
+// run in order and later ones win, so OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_SERVICE_NAME can override anything detected automatically.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOSType(),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithProcessRuntimeDescription(),
+		resource.WithContainer(),
+		resource.WithDetectors(serviceDetector{}, kubernetesDetector{}),
+		resource.WithFromEnv(),
+	)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+func resourceValue(res *resource.Resource, key attribute.Key) string {
+	v, _ := res.Set().Value(key)
+	return v.Emit()
+}
+
+// =============================================================================
+// Resource Detection Tests
+// =============================================================================
+
+func TestNewResource_Defaults(t *testing.T) {
+	res, err := newResource(context.Background())
+	if err != nil {
+		t.Fatalf("newResource returned error: %v", err)
+	}
+
+	if got := resourceValue(res, semconv.ServiceNameKey); got != serviceName {
+		t.Errorf("expected service.name %q, got %q", serviceName, got)
+	}
+	if got := resourceValue(res, semconv.ServiceVersionKey); got == "" {
+		t.Error("expected service.version to be set")
+	}
+	if got := resourceValue(res, semconv.ServiceInstanceIDKey); got == "" {
+		t.Error("expected service.instance.id to be set")
+	}
+	if got := resourceValue(res, semconv.ProcessPIDKey); got == "" {
+		t.Error("expected process.pid to be set")
+	}
+	if got := resourceValue(res, semconv.HostNameKey); got == "" {
+		t.Error("expected host.name to be set")
+	}
+}
+
+func TestNewResource_KubernetesDownwardAPI(t *testing.T) {
+	t.Setenv(envPodName, "menu-7c9f-abcde")
+	t.Setenv(envPodUID, "0d5c1c2e-1111-2222-3333-444455556666")
+	t.Setenv(envNamespaceName, "production")
+	t.Setenv(envNodeName, "docker-desktop")
+	t.Setenv(envContainerName, "friendly-octo-guacamole")
+	t.Setenv(envDeploymentName, "friendly-octo-guacamole")
+	t.Setenv(envEnvironment, "production")
+
+	res, err := newResource(context.Background())
+	if err != nil {
+		t.Fatalf("newResource returned error: %v", err)
+	}
+
+	expectations := map[attribute.Key]string{
+		semconv.K8SPodNameKey:                "menu-7c9f-abcde",
+		semconv.K8SPodUIDKey:                 "0d5c1c2e-1111-2222-3333-444455556666",
+		semconv.K8SNamespaceNameKey:          "production",
+		semconv.K8SNodeNameKey:               "docker-desktop",
+		semconv.K8SContainerNameKey:          "friendly-octo-guacamole",
+		semconv.K8SDeploymentNameKey:         "friendly-octo-guacamole",
+		semconv.DeploymentEnvironmentNameKey: "production",
+		// The pod UID doubles as the instance ID so it is stable per pod.
+		semconv.ServiceInstanceIDKey: "0d5c1c2e-1111-2222-3333-444455556666",
+	}
+
+	for key, expected := range expectations {
+		if got := resourceValue(res, key); got != expected {
+			t.Errorf("%s: expected %q, got %q", key, expected, got)
+		}
+	}
+}
+
+func TestNewResource_EnvironmentOverrides(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "menu-canary")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment.name=staging,team=sre")
+	t.Setenv(envEnvironment, "production")
+
+	res, err := newResource(context.Background())
+	if err != nil {
+		t.Fatalf("newResource returned error: %v", err)
+	}
+
+	if got := resourceValue(res, semconv.ServiceNameKey); got != "menu-canary" {
+		t.Errorf("expected OTEL_SERVICE_NAME to win, got %q", got)
+	}
+	if got := resourceValue(res, semconv.DeploymentEnvironmentNameKey); got != "staging" {
+		t.Errorf("expected OTEL_RESOURCE_ATTRIBUTES to win, got %q", got)
+	}
+	if got := resourceValue(res, "team"); got != "sre" {
+		t.Errorf("expected custom attribute 'sre', got %q", got)
+	}
+}
+
+func TestKubernetesDetector_OutsideCluster(t *testing.T) {
+	res, err := kubernetesDetector{}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect returned error: %v", err)
+	}
+
+	if res.Len() != 0 {
+		t.Errorf("expected no attributes outside a cluster, got %v", res.Attributes())
+	}
+}
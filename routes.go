@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// route is one ServeMux registration together with the operation it serves;
+// the operations make up the OpenAPI spec. Keeping both in one table is what
+// keeps the spec in step with the handlers.
+type route struct {
+	// pattern is a method and path, e.g. "GET /api/menu/{id}". Its wildcards
+	// use the same syntax as OpenAPI path templates.
+	pattern   string
+	handler   http.HandlerFunc
+	operation apiOperation
+}
+
+func (rt route) method() string {
+	method, _, _ := strings.Cut(rt.pattern, " ")
+	return method
+}
+
+func (rt route) path() string {
+	_, path, _ := strings.Cut(rt.pattern, " ")
+	return path
+}
+
+// canonicalPattern is the pattern with any /api/v1 or /api/v2 prefix reduced
+// to /api, so a versioned route and its unversioned alias share one name.
+func (rt route) canonicalPattern() string {
+	method, path, _ := strings.Cut(rt.pattern, " ")
+	for _, v := range []apiVersion{apiV1, apiV2} {
+		if rest, ok := strings.CutPrefix(path, "/api/"+string(v)+"/"); ok {
+			return method + " /api/" + rest
+		}
+	}
+	return rt.pattern
+}
+
+// isRoutePattern reports whether pattern looks like a route pattern, for
+// settings keyed by route.
+func isRoutePattern(pattern string) bool {
+	method, path, ok := strings.Cut(pattern, " ")
+	return ok && method != "" && strings.HasPrefix(path, "/")
+}
+
+// priority orders routes for load shedding; lower priorities are shed first.
+type priority int
+
+const (
+	priorityDefault  priority = iota // single reads, GraphQL, the API docs
+	priorityLow                      // listings, the most expensive reads
+	priorityHigh                     // orders
+	priorityCritical                 // health checks, which are never shed
+)
+
+func (p priority) String() string {
+	return [...]string{"default", "low", "high", "critical"}[p]
+}
+
+// priority derives the route's shedding priority from its operation: health
+// checks are critical, orders high, and list operations low.
+func (rt route) priority() priority {
+	switch {
+	case slices.Contains(rt.operation.Tags, "health"):
+		return priorityCritical
+	case slices.Contains(rt.operation.Tags, "orders"):
+		return priorityHigh
+	case strings.HasPrefix(rt.operation.ID, "list"):
+		return priorityLow
+	}
+	return priorityDefault
+}
+
+// admin reports whether the route is served on the admin listener, and only
+// there once one is configured: health checks, build information and the API
+// docs.
+func (rt route) admin() bool {
+	return slices.Contains(rt.operation.Tags, "health") || slices.Contains(rt.operation.Tags, "docs")
+}
+
+// streaming reports whether the route holds its connection open, as a
+// WebSocket or an event stream, rather than answering a single request.
+func (rt route) streaming() bool {
+	for _, resp := range rt.operation.Responses {
+		if resp.Status == http.StatusSwitchingProtocols || resp.ContentType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) routes() []route {
+	verbose := queryParam("verbose", "Include check errors and timings.", false, &jsonSchema{Type: "boolean"})
+	probeRoute := func(pattern, id, summary string, p probe) route {
+		return route{"GET " + pattern, s.health.handler(p), apiOperation{
+			ID: id, Summary: summary, Tags: []string{"health"},
+			Parameters: []apiParameter{verbose},
+			Responses: []apiResponse{
+				{Status: http.StatusOK, Description: "All required checks passed; status is degraded if an optional one failed", Body: ProbeReport{}},
+				{Status: http.StatusServiceUnavailable, Description: "A check failed", Body: ProbeReport{}},
+			},
+		}}
+	}
+	zero, one := 0.0, 1
+
+	routes := []route{
+		{"GET /health", s.healthHandler, apiOperation{
+			ID: "getHealth", Summary: "Basic health and version", Tags: []string{"health"},
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "Service is up", Body: HealthResponse{}}},
+		}},
+		{"GET /version", s.versionHandler, apiOperation{
+			ID: "getVersion", Summary: "Build information", Tags: []string{"health"},
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "Build information", Body: BuildInfo{}}},
+		}},
+		probeRoute("/livez", "getLiveness", "Liveness probe", probeLiveness),
+		probeRoute("/readyz", "getReadiness", "Readiness probe", probeReadiness),
+		probeRoute("/startupz", "getStartup", "Startup probe", probeStartup),
+	}
+	routes = append(routes, s.menuRoutes("")...)
+	routes = append(routes, s.menuRoutes(apiV1)...)
+	routes = append(routes, s.menuRoutes(apiV2)...)
+	return append(routes, []route{
+		{"GET /api/menu/stream", s.menuStreamHandler(menuStreamHeartbeat), apiOperation{
+			ID: "streamMenuEvents", Summary: "Stream menu changes as Server-Sent Events", Tags: []string{"menu"}, Scope: scopeMenuRead,
+			Parameters: []apiParameter{
+				{Name: "Last-Event-ID", In: "header", Description: "Resume after this event.", Schema: &jsonSchema{Type: "integer", Format: "int64", Minimum: &zero}},
+				queryParam("last_event_id", "Resume after this event when the header cannot be set.", false, &jsonSchema{Type: "integer", Format: "int64", Minimum: &zero}),
+			},
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "Event stream; each data line is a MenuEvent", Body: MenuEvent{}, ContentType: "text/event-stream"},
+			}, errorResponses(http.StatusBadRequest)...),
+		}},
+		{"PUT /api/menu/{id}", s.versioned("", s.updateMenuItemHandler), apiOperation{
+			ID: "putMenuItem", Summary: "Create or replace a menu item", Tags: []string{"menu"}, Scope: scopeMenuWrite,
+			Parameters:  []apiParameter{pathParam("id", "Menu item ID.")},
+			RequestBody: MenuItem{},
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "The replaced menu item", Body: MenuItemResponse{}, Negotiated: map[string]any{mediaTypeMenuV2: MenuItemResponseV2{}}},
+				{Status: http.StatusCreated, Description: "The created menu item", Body: MenuItemResponse{}, Negotiated: map[string]any{mediaTypeMenuV2: MenuItemResponseV2{}}},
+			}, errorResponses(http.StatusBadRequest, http.StatusInternalServerError)...),
+		}},
+		{"GET /ws/tablets", s.tabletHandler, apiOperation{
+			ID: "connectTablet", Summary: "Open the order WebSocket for a restaurant tablet", Tags: []string{"orders"}, Scope: scopeOrdersWrite,
+			Parameters: []apiParameter{queryParam("restaurant", "Restaurant the tablet belongs to.", true, &jsonSchema{Type: "string", MinLength: &one})},
+			Responses: append([]apiResponse{
+				{Status: http.StatusSwitchingProtocols, Description: "WebSocket established"},
+			}, errorResponses(http.StatusBadRequest)...),
+		}},
+		{"GET /graphql", s.graphQLHandler, apiOperation{
+			ID: "queryGraphQL", Summary: "Run a GraphQL query", Tags: []string{"graphql"}, Scope: scopeMenuRead,
+			Parameters: []apiParameter{
+				queryParam("query", "GraphQL document.", true, &jsonSchema{Type: "string", MinLength: &one}),
+				queryParam("operationName", "Operation to run when the document has several.", false, &jsonSchema{Type: "string"}),
+				queryParam("variables", "JSON-encoded variables.", false, &jsonSchema{Type: "string"}),
+			},
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "Query result, including query errors", Body: graphql.Result{}},
+			}, errorResponses(http.StatusBadRequest)...),
+		}},
+		{"POST /graphql", s.graphQLHandler, apiOperation{
+			ID: "postGraphQL", Summary: "Run a GraphQL query", Tags: []string{"graphql"}, Scope: scopeMenuRead,
+			RequestBody: graphQLRequest{},
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "Query result, including query errors", Body: graphql.Result{}},
+			}, errorResponses(http.StatusBadRequest)...),
+		}},
+		{"GET /openapi.json", openAPIHandler(s.routes), apiOperation{
+			ID: "getOpenAPI", Summary: "This document", Tags: []string{"docs"},
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "OpenAPI 3 document", Body: map[string]any{}}},
+		}},
+		{"GET /docs", docsHandler, apiOperation{
+			ID: "getDocs", Summary: "Interactive API documentation", Tags: []string{"docs"},
+			Responses: []apiResponse{{Status: http.StatusOK, Description: "Documentation page", ContentType: "text/html"}},
+		}},
+	}...)
+}
+
+// menuRoutes returns the menu list and item routes for version, under
+// /api/<version>/menu, or the unversioned /api/menu routes that negotiate the
+// version from Accept when version is empty.
+func (s *Server) menuRoutes(version apiVersion) []route {
+	prefix, idSuffix := "/api/menu", strings.ToUpper(string(version))
+	if version != "" {
+		prefix = "/api/" + string(version) + "/menu"
+	}
+
+	listBody, itemBody := any(MenuListResponse{}), any(MenuItemResponse{})
+	var negotiatedList, negotiatedItem map[string]any
+	switch version {
+	case apiV2:
+		listBody, itemBody = MenuListResponseV2{}, MenuItemResponseV2{}
+	case "":
+		negotiatedList = map[string]any{mediaTypeMenuV1: MenuListResponse{}, mediaTypeMenuV2: MenuListResponseV2{}}
+		negotiatedItem = map[string]any{mediaTypeMenuV1: MenuItemResponse{}, mediaTypeMenuV2: MenuItemResponseV2{}}
+	}
+
+	conditional := []apiParameter{
+		{Name: "If-None-Match", In: "header", Description: "ETag of a cached copy.", Schema: &jsonSchema{Type: "string"}},
+		{Name: "If-Modified-Since", In: "header", Description: "Last-Modified of a cached copy.", Schema: &jsonSchema{Type: "string"}},
+	}
+	notModified := apiResponse{Status: http.StatusNotModified, Description: "The cached copy is current"}
+
+	return []route{
+		{"GET " + prefix, s.versioned(version, s.menuHandler), apiOperation{
+			ID: "listMenuItems" + idSuffix, Summary: "List all menu items", Tags: []string{"menu"}, Scope: scopeMenuRead,
+			Parameters: conditional,
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "Menu items ordered by ID", Body: listBody, Negotiated: negotiatedList},
+				notModified,
+			}, errorResponses(http.StatusInternalServerError)...),
+		}},
+		{"GET " + prefix + "/{id}", s.versioned(version, s.menuItemByIDHandler), apiOperation{
+			ID: "getMenuItem" + idSuffix, Summary: "Get a menu item", Tags: []string{"menu"}, Scope: scopeMenuRead,
+			Parameters: append([]apiParameter{pathParam("id", "Menu item ID.")}, conditional...),
+			Responses: append([]apiResponse{
+				{Status: http.StatusOK, Description: "The menu item", Body: itemBody, Negotiated: negotiatedItem},
+				notModified,
+			}, errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError)...),
+		}},
+	}
+}
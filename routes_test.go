@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouting_WrongMethod(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	tests := []struct {
+		method, url, allow string
+	}{
+		{http.MethodPost, "/api/menu", "GET, HEAD"},
+		{http.MethodDelete, "/api/v2/menu/1", "GET, HEAD"},
+		{http.MethodPut, "/graphql", "GET, HEAD, POST"},
+		{http.MethodPost, "/health", "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("expected status 405, got %d", rec.Code)
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.allow {
+				t.Errorf("expected Allow %q, got %q", tt.allow, allow)
+			}
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if body.Error != "Method Not Allowed" {
+				t.Errorf("expected error 'Method Not Allowed', got %q", body.Error)
+			}
+		})
+	}
+}
+
+func TestRouting_RejectsNestedAndEmptyPaths(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	for _, url := range []string{"/api/menu/1/extra", "/api/v1/menu/1/2", "/api/menu/", "/api/v2/menu/"} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept", problemContentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", url, rec.Code)
+			continue
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: failed to decode problem: %v", url, err)
+		}
+		if p.Code != errCodeRouteNotFound {
+			t.Errorf("%s: expected code %s, got %s", url, errCodeRouteNotFound, p.Code)
+		}
+	}
+}
+
+func TestRouting_HeadAllowedOnGetRoutes(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/api/menu/1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestRouting_RouteTagIsPathTemplate(t *testing.T) {
+	recorder := installSpanRecorder()
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	ctx, root := tracer.Start(context.Background(), "client")
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/menu/3", nil).WithContext(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	root.End()
+
+	var routes []string
+	for _, span := range spansInTrace(recorder, root.SpanContext().TraceID()) {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "http.route" {
+				routes = append(routes, attr.Value.AsString())
+			}
+		}
+	}
+	if len(routes) == 0 || routes[0] != "/api/v2/menu/{id}" {
+		t.Errorf("expected http.route /api/v2/menu/{id}, got %v", routes)
+	}
+}
+
+func TestAdminHandler_ServesOnlyAdminRoutes(t *testing.T) {
+	handler := newAdminHandler(newTestServerWithoutFaults())
+
+	for url, want := range map[string]int{
+		"/health":       http.StatusOK,
+		"/livez":        http.StatusOK,
+		"/version":      http.StatusOK,
+		"/openapi.json": http.StatusOK,
+		"/docs":         http.StatusOK,
+		"/api/menu":     http.StatusNotFound,
+		"/graphql":      http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", url, want, rec.Code)
+		}
+	}
+}
+
+func TestHTTPHandler_LeavesAdminRoutesToAdminListener(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.adminListener = true
+	handler := newHTTPHandler(server)
+
+	for url, want := range map[string]int{
+		"/health":       http.StatusNotFound,
+		"/livez":        http.StatusNotFound,
+		"/readyz":       http.StatusNotFound,
+		"/version":      http.StatusNotFound,
+		"/openapi.json": http.StatusNotFound,
+		"/docs":         http.StatusNotFound,
+		"/api/menu":     http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", url, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// menuStreamHeartbeat keeps idle streams alive through proxies that close
+	// quiet connections.
+	menuStreamHeartbeat = 15 * time.Second
+
+	// menuStreamRetry is the reconnect delay suggested to EventSource clients.
+	menuStreamRetry = 3 * time.Second
+)
+
+// menuStreamHandler serves menu changes as Server-Sent Events. Clients resume
+// after a disconnect with the Last-Event-ID header (or last_event_id query
+// parameter, for the first connection); when the missed events are no longer
+// retained a "reset" event tells them to refetch /api/menu.
+func (s *Server) menuStreamHandler(heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "streamMenuEvents")
+		defer span.End()
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+		var resumeFrom uint64
+		if lastEventID != "" {
+			id, err := strconv.ParseUint(lastEventID, 10, 64)
+			if err != nil {
+				span.SetAttributes(attribute.Bool("error", true))
+				writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("Invalid Last-Event-ID '%s'", lastEventID))
+				return
+			}
+			resumeFrom = id
+			span.SetAttributes(attribute.Int64("menu.stream.resume_from", int64(id)))
+		}
+
+		// Streams outlive the server's write timeout.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn().Err(err).Msg("Could not clear write deadline for event stream")
+		}
+
+		events, cancel := s.menu.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", menuStreamRetry.Milliseconds())
+
+		var sent int
+		lastSent := resumeFrom
+		send := func(event MenuEvent) error {
+			if event.ID <= lastSent {
+				return nil
+			}
+			if err := writeSSE(w, strconv.FormatUint(event.ID, 10), string(event.Type), event); err != nil {
+				return err
+			}
+			lastSent = event.ID
+			sent++
+			return nil
+		}
+		reason := "client disconnected"
+		defer func() {
+			span.SetAttributes(
+				attribute.Int("menu.stream.events_sent", sent),
+				attribute.Int64("menu.stream.last_event_id", int64(lastSent)),
+				attribute.String("menu.stream.end_reason", reason),
+			)
+		}()
+
+		if lastEventID != "" {
+			missed, err := s.menu.EventsSince(ctx, resumeFrom)
+			if errors.Is(err, ErrMenuHistoryExpired) {
+				span.AddEvent("menu.stream.reset")
+				_ = writeSSE(w, "", "reset", map[string]string{"message": "Missed events are no longer available, refetch /api/menu"})
+				lastSent = 0
+			} else if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				reason = "history unavailable"
+				return
+			}
+			for _, event := range missed {
+				if err := send(event); err != nil {
+					return
+				}
+			}
+		}
+		_ = rc.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.closing:
+				reason = "server shutting down"
+				return
+			case <-ticker.C:
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case event, ok := <-events:
+				if !ok {
+					// The client reconnects with Last-Event-ID and catches up
+					// from history.
+					reason = "subscriber fell behind"
+					return
+				}
+				if err := send(event); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeSSE writes one event; id may be empty for events that should not move
+// the client's resume position.
+func writeSSE(w io.Writer, id, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
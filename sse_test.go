@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one parsed Server-Sent Event or comment.
+type sseEvent struct {
+	id, event, data, comment, retry string
+}
+
+// openMenuStream connects to /api/menu/stream through the full middleware
+// chain and returns a channel of parsed events once the stream is subscribed.
+func openMenuStream(t *testing.T, server *Server, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	ts := httptest.NewServer(loggingMiddleware(server.inFlightMiddleware(newHTTPHandler(server))))
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/menu/stream", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				ev.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "retry: "):
+				ev.retry = line[len("retry: "):]
+			case strings.HasPrefix(line, "id: "):
+				ev.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				ev.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				ev.data = line[len("data: "):]
+			}
+		}
+	}()
+
+	// The retry hint is flushed after the handler has subscribed.
+	select {
+	case ev := <-events:
+		if ev.retry == "" {
+			t.Fatalf("expected a retry hint first, got %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the stream to open")
+	}
+	return events
+}
+
+// nextSSE returns the next event or comment.
+func nextSSE(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("stream closed unexpectedly")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for stream event")
+	}
+	return sseEvent{}
+}
+
+func updateAvailability(t *testing.T, server *Server, id string, available bool) {
+	t.Helper()
+
+	item, err := server.menu.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	item.Available = available
+	if err := server.menu.Put(context.Background(), item); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+}
+
+// =============================================================================
+// /api/menu/stream Tests
+// =============================================================================
+
+func TestMenuStream_PushesChanges(t *testing.T) {
+	server := NewServer()
+	events := openMenuStream(t, server, "")
+
+	updateAvailability(t, server, "3", true)
+	_ = server.menu.Delete(context.Background(), "4")
+
+	ev := nextSSE(t, events)
+	if ev.id != "1" || ev.event != "availability" || !strings.Contains(ev.data, `"available":true`) {
+		t.Errorf("unexpected availability event: %+v", ev)
+	}
+	ev = nextSSE(t, events)
+	if ev.id != "2" || ev.event != "deleted" || !strings.Contains(ev.data, `"id":"4"`) {
+		t.Errorf("unexpected delete event: %+v", ev)
+	}
+}
+
+func TestMenuStream_ResumesFromLastEventID(t *testing.T) {
+	server := NewServer()
+	updateAvailability(t, server, "1", false)
+	updateAvailability(t, server, "2", false)
+	updateAvailability(t, server, "3", true)
+
+	events := openMenuStream(t, server, "1")
+
+	for _, id := range []string{"2", "3"} {
+		if ev := nextSSE(t, events); ev.id != id {
+			t.Errorf("expected missed event %s, got %+v", id, ev)
+		}
+	}
+}
+
+func TestMenuStream_ResetsWhenHistoryExpired(t *testing.T) {
+	server := NewServer()
+	events := openMenuStream(t, server, "42")
+
+	if ev := nextSSE(t, events); ev.event != "reset" || ev.id != "" {
+		t.Errorf("expected reset event without an ID, got %+v", ev)
+	}
+}
+
+func TestMenuStream_InvalidLastEventID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/menu/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+
+	NewServer().menuStreamHandler(time.Second)(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestMenuStream_Heartbeat(t *testing.T) {
+	server := NewServer()
+	ts := httptest.NewServer(server.menuStreamHandler(10 * time.Millisecond))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if scanner.Text() == ": heartbeat" {
+			return
+		}
+	}
+	t.Fatal("stream ended without a heartbeat")
+}
+
+func TestMenuStream_EndsOnDrain(t *testing.T) {
+	server := NewServer()
+	events := openMenuStream(t, server, "")
+
+	_ = server.drain(ShutdownConfig{Timeout: time.Second})
+
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream was not closed on drain")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// menuSubscriberBuffer is how many events a subscriber may fall behind
+	// before it is disconnected.
+	menuSubscriberBuffer = 64
+
+	// menuEventHistory is how many recent events are kept for clients resuming
+	// a stream.
+	menuEventHistory = 1000
+)
+
+var (
+	ErrMenuItemNotFound   = errors.New("menu item not found")
+	ErrMenuHistoryExpired = errors.New("menu events are no longer available")
+)
+
+type MenuEventType string
+
+const (
+	MenuItemCreated             MenuEventType = "created"
+	MenuItemUpdated             MenuEventType = "updated"
+	MenuItemDeleted             MenuEventType = "deleted"
+	MenuItemAvailabilityChanged MenuEventType = "availability"
+)
+
+// MenuEvent is one change to the menu. IDs increase monotonically per store.
+type MenuEvent struct {
+	ID   uint64        `json:"id"`
+	Type MenuEventType `json:"type"`
+	Item MenuItem      `json:"item"`
+	Time time.Time     `json:"time"`
+}
+
+// MenuRevision identifies a state of the menu. ID is the last event's ID and
+// changes with every write; Modified is when that write happened.
+type MenuRevision struct {
+	ID       uint64
+	Modified time.Time
+}
+
+// MenuStore is the source of menu items shared by every API surface. Its
+// methods fail with the context's error once ctx is done, without applying
+// or publishing anything.
+type MenuStore interface {
+	List(ctx context.Context) ([]MenuItem, error)
+	Get(ctx context.Context, id string) (MenuItem, error)
+	Put(ctx context.Context, item MenuItem) error
+	Delete(ctx context.Context, id string) error
+
+	// Revision returns the menu's current revision.
+	Revision(ctx context.Context) (MenuRevision, error)
+
+	// Subscribe streams every change made after it returns. The channel is
+	// closed when cancel is called or the subscriber falls too far behind.
+	Subscribe() (events <-chan MenuEvent, cancel func())
+
+	// EventsSince returns the retained events after afterID, oldest first, or
+	// ErrMenuHistoryExpired if some of them have been discarded.
+	EventsSince(ctx context.Context, afterID uint64) ([]MenuEvent, error)
+}
+
+type memoryMenuStore struct {
+	mu       sync.RWMutex
+	items    map[string]MenuItem
+	lastID   uint64
+	modified time.Time
+	history  []MenuEvent
+	subs     map[chan MenuEvent]struct{}
+}
+
+func newMemoryMenuStore(items []MenuItem) *memoryMenuStore {
+	m := &memoryMenuStore{
+		items:    make(map[string]MenuItem, len(items)),
+		modified: time.Now(),
+		subs:     map[chan MenuEvent]struct{}{},
+	}
+	for _, item := range items {
+		m.items[item.ID] = item
+	}
+	return m
+}
+
+// List returns every item ordered by ID.
+func (m *memoryMenuStore) List(ctx context.Context) ([]MenuItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]MenuItem, 0, len(m.items))
+	for _, item := range m.items {
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b MenuItem) int { return strings.Compare(a.ID, b.ID) })
+	return items, nil
+}
+
+func (m *memoryMenuStore) Get(ctx context.Context, id string) (MenuItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return MenuItem{}, err
+	}
+
+	item, ok := m.items[id]
+	if !ok {
+		return MenuItem{}, ErrMenuItemNotFound
+	}
+	return item, nil
+}
+
+// Put creates or replaces an item. A replacement that only flips Available is
+// published as an availability change.
+func (m *memoryMenuStore) Put(ctx context.Context, item MenuItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Checked once the lock is held, so a write whose caller gave up while
+	// waiting for it is neither applied nor published.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	eventType := MenuItemCreated
+	if prev, ok := m.items[item.ID]; ok {
+		if prev == item {
+			return nil
+		}
+		eventType = MenuItemUpdated
+		prev.Available = item.Available
+		if prev == item {
+			eventType = MenuItemAvailabilityChanged
+		}
+	}
+
+	m.items[item.ID] = item
+	m.publish(eventType, item)
+	return nil
+}
+
+func (m *memoryMenuStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	item, ok := m.items[id]
+	if !ok {
+		return ErrMenuItemNotFound
+	}
+	delete(m.items, id)
+	m.publish(MenuItemDeleted, item)
+	return nil
+}
+
+func (m *memoryMenuStore) Revision(ctx context.Context) (MenuRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return MenuRevision{}, err
+	}
+
+	return MenuRevision{ID: m.lastID, Modified: m.modified}, nil
+}
+
+func (m *memoryMenuStore) EventsSince(ctx context.Context, afterID uint64) ([]MenuEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if afterID > m.lastID {
+		// An ID from before a restart; the sequence has started over.
+		return nil, ErrMenuHistoryExpired
+	}
+	if afterID == m.lastID {
+		return nil, nil
+	}
+	if len(m.history) == 0 || m.history[0].ID > afterID+1 {
+		return nil, ErrMenuHistoryExpired
+	}
+	return slices.Clone(m.history[afterID+1-m.history[0].ID:]), nil
+}
+
+func (m *memoryMenuStore) Subscribe() (<-chan MenuEvent, func()) {
+	ch := make(chan MenuEvent, menuSubscriberBuffer)
+
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.unsubscribe(ch)
+	}
+}
+
+// publish must be called with mu held, so events are delivered in ID order.
+func (m *memoryMenuStore) publish(eventType MenuEventType, item MenuItem) {
+	m.lastID++
+	event := MenuEvent{ID: m.lastID, Type: eventType, Item: item, Time: time.Now()}
+	m.modified = event.Time
+
+	m.history = append(m.history, event)
+	if len(m.history) > menuEventHistory {
+		m.history = slices.Delete(m.history, 0, len(m.history)-menuEventHistory)
+	}
+
+	for ch := range m.subs {
+		select {
+		case ch <- event:
+		default:
+			m.unsubscribe(ch)
+		}
+	}
+}
+
+func (m *memoryMenuStore) unsubscribe(ch chan MenuEvent) {
+	if _, ok := m.subs[ch]; ok {
+		delete(m.subs, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, events <-chan MenuEvent) MenuEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("subscription closed unexpectedly")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for menu event")
+	}
+	return MenuEvent{}
+}
+
+// =============================================================================
+// memoryMenuStore Tests
+// =============================================================================
+
+func TestMemoryMenuStore_ListIsOrdered(t *testing.T) {
+	store := newMemoryMenuStore([]MenuItem{{ID: "3"}, {ID: "1"}, {ID: "2"}})
+
+	items, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	for i, id := range []string{"1", "2", "3"} {
+		if items[i].ID != id {
+			t.Errorf("expected item %d to be %q, got %q", i, id, items[i].ID)
+		}
+	}
+}
+
+func TestMemoryMenuStore_GetMissing(t *testing.T) {
+	store := newMemoryMenuStore(nil)
+
+	if _, err := store.Get(context.Background(), "42"); !errors.Is(err, ErrMenuItemNotFound) {
+		t.Errorf("expected ErrMenuItemNotFound, got %v", err)
+	}
+	if err := store.Delete(context.Background(), "42"); !errors.Is(err, ErrMenuItemNotFound) {
+		t.Errorf("expected ErrMenuItemNotFound from Delete, got %v", err)
+	}
+}
+
+func TestMemoryMenuStore_PublishesChanges(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryMenuStore(nil)
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	item := MenuItem{ID: "1", Name: "Margherita Pizza", Price: 12.99, Available: true}
+	unavailable := item
+	unavailable.Available = false
+	repriced := unavailable
+	repriced.Price = 13.99
+
+	_ = store.Put(ctx, item)
+	_ = store.Put(ctx, item) // unchanged, not published
+	_ = store.Put(ctx, unavailable)
+	_ = store.Put(ctx, repriced)
+	_ = store.Delete(ctx, "1")
+
+	expected := []MenuEventType{MenuItemCreated, MenuItemAvailabilityChanged, MenuItemUpdated, MenuItemDeleted}
+	for i, eventType := range expected {
+		event := receiveEvent(t, events)
+		if event.Type != eventType {
+			t.Errorf("event %d: expected type %q, got %q", i, eventType, event.Type)
+		}
+		if event.ID != uint64(i+1) {
+			t.Errorf("event %d: expected ID %d, got %d", i, i+1, event.ID)
+		}
+	}
+}
+
+func TestMemoryMenuStore_DropsSlowSubscriber(t *testing.T) {
+	store := newMemoryMenuStore(nil)
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	for i := 0; i <= menuSubscriberBuffer; i++ {
+		_ = store.Put(context.Background(), MenuItem{ID: "1", PrepTime: i})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != menuSubscriberBuffer {
+		t.Errorf("expected %d buffered events before the channel closed, got %d", menuSubscriberBuffer, received)
+	}
+}
+
+func TestMemoryMenuStore_RevisionAdvancesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryMenuStore([]MenuItem{{ID: "1"}})
+
+	before, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("Revision returned error: %v", err)
+	}
+	if again, _ := store.Revision(ctx); again != before {
+		t.Errorf("expected revision to be stable without writes, got %+v then %+v", before, again)
+	}
+
+	if err := store.Put(ctx, MenuItem{ID: "2"}); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	after, _ := store.Revision(ctx)
+	if after.ID == before.ID {
+		t.Errorf("expected revision to change after Put, stayed %d", after.ID)
+	}
+	if after.Modified.Before(before.Modified) {
+		t.Errorf("expected Modified to move forward, got %v after %v", after.Modified, before.Modified)
+	}
+}
+
+func TestMemoryMenuStore_StopsOnceContextIsDone(t *testing.T) {
+	store := newMemoryMenuStore([]MenuItem{{ID: "1"}})
+	events, cancelSub := store.Subscribe()
+	defer cancelSub()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if _, err := store.List(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected List to fail with DeadlineExceeded, got %v", err)
+	}
+	if _, err := store.Get(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Get to fail with DeadlineExceeded, got %v", err)
+	}
+	if err := store.Put(ctx, MenuItem{ID: "2"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Put to fail with DeadlineExceeded, got %v", err)
+	}
+	if err := store.Delete(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Delete to fail with DeadlineExceeded, got %v", err)
+	}
+
+	items, _ := store.List(context.Background())
+	if len(items) != 1 || items[0].ID != "1" {
+		t.Errorf("expected writes past their deadline not to be applied, got %+v", items)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("expected nothing to be published, got %+v", event)
+	default:
+	}
+}
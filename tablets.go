@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tabletSendBuffer is how many orders may queue for a tablet before new
+	// ones are left for redelivery on reconnect.
+	tabletSendBuffer = 32
+
+	tabletWriteTimeout = 5 * time.Second
+
+	// tabletMaxPending is how many unacknowledged orders are kept per
+	// restaurant. Beyond it the oldest are dropped, so a restaurant whose
+	// tablets never connect cannot grow the hub without bound.
+	tabletMaxPending = 500
+
+	// tabletPingInterval keeps idle connections open through proxies with
+	// read timeouts, such as the ingress.
+	tabletPingInterval = 30 * time.Second
+)
+
+// tabletMessage is the JSON frame exchanged with tablets. The server sends
+// "order" and "error"; tablets send "ack".
+type tabletMessage struct {
+	Type       string `json:"type"`
+	Order      *Order `json:"order,omitempty"`
+	OrderID    string `json:"order_id,omitempty"`
+	Redelivery bool   `json:"redelivery,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+type pendingOrder struct {
+	order     Order
+	delivered bool
+}
+
+type tabletConn struct {
+	send chan tabletMessage
+}
+
+// tabletHub routes orders to the tablets connected for each restaurant and
+// keeps every order pending until one of them acknowledges it. Pending orders
+// are redelivered whenever a tablet for the restaurant connects, up to
+// maxPending per restaurant.
+type tabletHub struct {
+	mu         sync.Mutex
+	maxPending int
+	pending    map[string]map[string]*pendingOrder
+	dropped    map[string]int64
+	tablets    map[string]map[*tabletConn]struct{}
+}
+
+func newTabletHub() *tabletHub {
+	return &tabletHub{
+		maxPending: tabletMaxPending,
+		pending:    map[string]map[string]*pendingOrder{},
+		dropped:    map[string]int64{},
+		tablets:    map[string]map[*tabletConn]struct{}{},
+	}
+}
+
+// Dispatch records order as pending and pushes it to the restaurant's
+// connected tablets. It fails with the context's error, leaving the order
+// unrecorded, if ctx is done before the order is taken.
+func (h *tabletHub) Dispatch(ctx context.Context, order Order) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p := &pendingOrder{order: order}
+	if h.pending[order.Restaurant] == nil {
+		h.pending[order.Restaurant] = map[string]*pendingOrder{}
+	}
+	h.pending[order.Restaurant][order.ID] = p
+	h.dropOldest(order.Restaurant)
+
+	for tc := range h.tablets[order.Restaurant] {
+		select {
+		case tc.send <- tabletMessage{Type: "order", Order: &p.order}:
+			p.delivered = true
+		default:
+			log.Warn().
+				Str("order_id", order.ID).
+				Str("restaurant", order.Restaurant).
+				Msg("Tablet is not keeping up, order will be redelivered on reconnect")
+		}
+	}
+	return nil
+}
+
+// dropOldest forgets the restaurant's oldest pending orders until at most
+// maxPending remain. The caller must hold h.mu.
+func (h *tabletHub) dropOldest(restaurant string) {
+	orders := h.pending[restaurant]
+	for len(orders) > h.maxPending {
+		var oldest *pendingOrder
+		for _, p := range orders {
+			if oldest == nil || p.order.CreatedAt.Before(oldest.order.CreatedAt) {
+				oldest = p
+			}
+		}
+		delete(orders, oldest.order.ID)
+		h.dropped[restaurant]++
+		log.Warn().
+			Str("order_id", oldest.order.ID).
+			Str("restaurant", restaurant).
+			Int("max_pending", h.maxPending).
+			Msg("Too many unacknowledged orders, dropping the oldest")
+	}
+}
+
+// attach registers a tablet and returns the restaurant's pending orders,
+// oldest first, to deliver before anything sent on the connection.
+func (h *tabletHub) attach(restaurant string) (*tabletConn, []tabletMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tc := &tabletConn{send: make(chan tabletMessage, tabletSendBuffer)}
+	if h.tablets[restaurant] == nil {
+		h.tablets[restaurant] = map[*tabletConn]struct{}{}
+	}
+	h.tablets[restaurant][tc] = struct{}{}
+
+	var backlog []tabletMessage
+	for _, p := range h.pending[restaurant] {
+		backlog = append(backlog, tabletMessage{Type: "order", Order: &p.order, Redelivery: p.delivered})
+		p.delivered = true
+	}
+	slices.SortFunc(backlog, func(a, b tabletMessage) int { return a.Order.CreatedAt.Compare(b.Order.CreatedAt) })
+	return tc, backlog
+}
+
+func (h *tabletHub) detach(restaurant string, tc *tabletConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.tablets[restaurant], tc)
+	if len(h.tablets[restaurant]) == 0 {
+		delete(h.tablets, restaurant)
+	}
+}
+
+// ack marks an order as handled. It reports false for unknown orders,
+// including ones another tablet already acknowledged.
+func (h *tabletHub) ack(restaurant, orderID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.pending[restaurant][orderID]; !ok {
+		return false
+	}
+	delete(h.pending[restaurant], orderID)
+	if len(h.pending[restaurant]) == 0 {
+		delete(h.pending, restaurant)
+	}
+	return true
+}
+
+// counts returns the number of unacknowledged orders and connected tablets
+// per restaurant.
+func (h *tabletHub) counts() (unacked, connected map[string]int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	unacked, connected = map[string]int{}, map[string]int{}
+	for restaurant, orders := range h.pending {
+		unacked[restaurant] = len(orders)
+	}
+	for restaurant, tablets := range h.tablets {
+		connected[restaurant] = len(tablets)
+	}
+	return unacked, connected
+}
+
+// droppedCounts returns the number of orders dropped per restaurant since the
+// hub was created.
+func (h *tabletHub) droppedCounts() map[string]int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return maps.Clone(h.dropped)
+}
+
+// tabletHandler upgrades to a WebSocket for the tablet of the restaurant named
+// in the "restaurant" query parameter. A token tied to another restaurant is
+// refused.
+func (s *Server) tabletHandler(w http.ResponseWriter, r *http.Request) {
+	restaurant := r.URL.Query().Get("restaurant")
+	if restaurant == "" {
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "restaurant query parameter is required")
+		return
+	}
+	if p := principalFrom(r.Context()); p != nil && !p.mayActFor(restaurant) {
+		writeError(w, r, http.StatusForbidden, errCodeForbidden,
+			fmt.Sprintf("Not allowed to take orders for restaurant '%s'", restaurant))
+		return
+	}
+
+	// The connection outlives the server's read and write timeouts.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		log.Warn().Err(err).Str("restaurant", restaurant).Msg("Tablet WebSocket upgrade failed")
+		return
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	ctx, span := tracer.Start(r.Context(), "tabletSession",
+		trace.WithAttributes(attribute.String("tablet.restaurant", restaurant)))
+	defer span.End()
+
+	tc, backlog := s.tablets.attach(restaurant)
+	defer s.tablets.detach(restaurant, tc)
+
+	log.Info().Str("restaurant", restaurant).Int("pending", len(backlog)).Msg("Tablet connected")
+
+	send := func(msg tabletMessage) error {
+		writeCtx, cancel := context.WithTimeout(ctx, tabletWriteTimeout)
+		defer cancel()
+		if msg.Order != nil {
+			span.AddEvent("tablet.order.sent", trace.WithAttributes(
+				attribute.String("order.id", msg.Order.ID),
+				attribute.Bool("order.redelivery", msg.Redelivery),
+			))
+		}
+		return wsjson.Write(writeCtx, conn, msg)
+	}
+
+	for _, msg := range backlog {
+		if err := send(msg); err != nil {
+			span.RecordError(err)
+			return
+		}
+	}
+
+	received := make(chan tabletMessage, tabletSendBuffer)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var msg tabletMessage
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case received <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(tabletPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ping.C:
+			pingCtx, cancel := context.WithTimeout(ctx, tabletWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				span.RecordError(err)
+				log.Info().Str("restaurant", restaurant).Msg("Tablet stopped responding to pings")
+				return
+			}
+		case <-s.closing:
+			_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+			return
+		case err := <-readErr:
+			if websocket.CloseStatus(err) == -1 {
+				span.RecordError(err)
+			}
+			log.Info().Str("restaurant", restaurant).Msg("Tablet disconnected")
+			return
+		case msg := <-tc.send:
+			if err := send(msg); err != nil {
+				span.RecordError(err)
+				return
+			}
+		case msg := <-received:
+			if msg.Type != "ack" || msg.OrderID == "" {
+				_ = send(tabletMessage{Type: "error", Message: "expected {\"type\": \"ack\", \"order_id\": ...}"})
+				continue
+			}
+			acked := s.tablets.ack(restaurant, msg.OrderID)
+			span.AddEvent("tablet.order.acked", trace.WithAttributes(
+				attribute.String("order.id", msg.OrderID),
+				attribute.Bool("order.known", acked),
+			))
+		}
+	}
+}
+
+// registerTabletMetrics publishes unacknowledged orders, dropped orders and
+// connected tablets per restaurant.
+func registerTabletMetrics(meter metric.Meter, h *tabletHub) error {
+	unackedGauge, err := meter.Int64ObservableGauge("tablet.orders.unacknowledged",
+		metric.WithDescription("Orders pushed to restaurant tablets and not yet acknowledged."),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		return err
+	}
+	droppedCounter, err := meter.Int64ObservableCounter("tablet.orders.dropped",
+		metric.WithDescription("Unacknowledged orders dropped because a restaurant had too many pending."),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		return err
+	}
+	connectedGauge, err := meter.Int64ObservableGauge("tablet.connections",
+		metric.WithDescription("Restaurant tablets currently connected."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		unacked, connected := h.counts()
+		for restaurant, n := range unacked {
+			o.ObserveInt64(unackedGauge, int64(n), metric.WithAttributes(attribute.String("restaurant", restaurant)))
+		}
+		for restaurant, n := range h.droppedCounts() {
+			o.ObserveInt64(droppedCounter, n, metric.WithAttributes(attribute.String("restaurant", restaurant)))
+		}
+		for restaurant, n := range connected {
+			o.ObserveInt64(connectedGauge, int64(n), metric.WithAttributes(attribute.String("restaurant", restaurant)))
+		}
+		return nil
+	}, unackedGauge, droppedCounter, connectedGauge)
+	return err
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/golang-jwt/jwt/v5"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func testOrder(id, restaurant string, createdAt time.Time) Order {
+	return Order{ID: id, Restaurant: restaurant, CreatedAt: createdAt}
+}
+
+// dialTablet connects a tablet for restaurant through the full middleware
+// chain.
+func dialTablet(t *testing.T, ts *httptest.Server, restaurant string) *websocket.Conn {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/tablets?restaurant=" + url.QueryEscape(restaurant)
+	conn, _, err := websocket.Dial(ctx, u, &websocket.DialOptions{
+		HTTPHeader: http.Header{apiKeyHeader: {testTabletKey}},
+	})
+	if err != nil {
+		t.Fatalf("failed to dial tablet: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.CloseNow() })
+	return conn
+}
+
+func readTablet(t *testing.T, conn *websocket.Conn) tabletMessage {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var msg tabletMessage
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatalf("failed to read from tablet connection: %v", err)
+	}
+	return msg
+}
+
+// testTabletKey is the API key tablets dial newTabletTestServer with.
+const testTabletKey = "tablet-secret"
+
+func newTabletTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+
+	server := NewServer()
+	keys, err := loadAPIKeys(AuthConfig{APIKeys: map[string]string{"tablet": hashedAPIKey(testTabletKey, "orders:write")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.auth.keys.Store(keys)
+	ts := httptest.NewServer(loggingMiddleware(server.inFlightMiddleware(newHTTPHandler(server))))
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+// =============================================================================
+// tabletHub Tests
+// =============================================================================
+
+func TestTabletHub_TracksUntilAcked(t *testing.T) {
+	hub := newTabletHub()
+	now := time.Now()
+	hub.Dispatch(context.Background(), testOrder("b", "Thai Palace", now.Add(time.Second)))
+	hub.Dispatch(context.Background(), testOrder("a", "Thai Palace", now))
+	hub.Dispatch(context.Background(), testOrder("c", "Sakura Sushi", now))
+
+	tc, backlog := hub.attach("Thai Palace")
+	defer hub.detach("Thai Palace", tc)
+
+	if len(backlog) != 2 || backlog[0].Order.ID != "a" || backlog[1].Order.ID != "b" {
+		t.Fatalf("expected pending orders a, b oldest first, got %+v", backlog)
+	}
+	if backlog[0].Redelivery {
+		t.Error("expected first delivery not to be flagged as a redelivery")
+	}
+
+	if !hub.ack("Thai Palace", "a") {
+		t.Error("expected ack of a pending order to succeed")
+	}
+	if hub.ack("Thai Palace", "a") {
+		t.Error("expected a second ack of the same order to report false")
+	}
+	if hub.ack("Sakura Sushi", "b") {
+		t.Error("expected ack from another restaurant to report false")
+	}
+
+	unacked, connected := hub.counts()
+	if unacked["Thai Palace"] != 1 || unacked["Sakura Sushi"] != 1 {
+		t.Errorf("unexpected unacknowledged counts: %v", unacked)
+	}
+	if connected["Thai Palace"] != 1 || len(connected) != 1 {
+		t.Errorf("unexpected connection counts: %v", connected)
+	}
+}
+
+func TestTabletHub_DispatchHonoursContext(t *testing.T) {
+	hub := newTabletHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := hub.Dispatch(ctx, testOrder("a", "Thai Palace", time.Now())); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Dispatch to fail with Canceled, got %v", err)
+	}
+	if unacked, _ := hub.counts(); len(unacked) != 0 {
+		t.Errorf("expected the order not to be recorded, got %v", unacked)
+	}
+}
+
+func TestTabletHub_DropsOldestBeyondCap(t *testing.T) {
+	hub := newTabletHub()
+	hub.maxPending = 2
+	now := time.Now()
+	hub.Dispatch(context.Background(), testOrder("b", "Thai Palace", now.Add(time.Second)))
+	hub.Dispatch(context.Background(), testOrder("a", "Thai Palace", now))
+	hub.Dispatch(context.Background(), testOrder("c", "Thai Palace", now.Add(2*time.Second)))
+	hub.Dispatch(context.Background(), testOrder("d", "Sakura Sushi", now))
+
+	tc, backlog := hub.attach("Thai Palace")
+	defer hub.detach("Thai Palace", tc)
+
+	if len(backlog) != 2 || backlog[0].Order.ID != "b" || backlog[1].Order.ID != "c" {
+		t.Fatalf("expected the oldest order to be dropped leaving b, c, got %+v", backlog)
+	}
+	if dropped := hub.droppedCounts(); dropped["Thai Palace"] != 1 || len(dropped) != 1 {
+		t.Errorf("unexpected dropped counts: %v", dropped)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := registerTabletMetrics(provider.Meter("test"), hub); err != nil {
+		t.Fatalf("registerTabletMetrics returned error: %v", err)
+	}
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "tablet.orders.dropped" {
+			continue
+		}
+		points := m.Data.(metricdata.Sum[int64]).DataPoints
+		if len(points) != 1 || points[0].Value != 1 {
+			t.Fatalf("expected one restaurant with 1 dropped order, got %+v", points)
+		}
+		return
+	}
+	t.Fatal("tablet.orders.dropped was not reported")
+}
+
+// =============================================================================
+// /ws/tablets Tests
+// =============================================================================
+
+func TestTabletHandler_PushAckAndRedeliver(t *testing.T) {
+	server, ts := newTabletTestServer(t)
+
+	conn := dialTablet(t, ts, "Tony's Pizza")
+	waitForTablets(t, server.tablets, "Tony's Pizza", 1)
+
+	server.tablets.Dispatch(context.Background(), testOrder("o1", "Tony's Pizza", time.Now()))
+	server.tablets.Dispatch(context.Background(), testOrder("o2", "Tony's Pizza", time.Now()))
+	server.tablets.Dispatch(context.Background(), testOrder("o3", "Thai Palace", time.Now()))
+
+	for _, id := range []string{"o1", "o2"} {
+		if msg := readTablet(t, conn); msg.Type != "order" || msg.Order.ID != id || msg.Redelivery {
+			t.Fatalf("expected first delivery of %s, got %+v", id, msg)
+		}
+	}
+	if err := wsjson.Write(context.Background(), conn, tabletMessage{Type: "ack", OrderID: "o1"}); err != nil {
+		t.Fatalf("failed to ack: %v", err)
+	}
+	waitForUnacked(t, server.tablets, "Tony's Pizza", 1)
+
+	_ = conn.Close(websocket.StatusNormalClosure, "")
+	waitForTablets(t, server.tablets, "Tony's Pizza", 0)
+
+	reconnected := dialTablet(t, ts, "Tony's Pizza")
+	if msg := readTablet(t, reconnected); msg.Order == nil || msg.Order.ID != "o2" || !msg.Redelivery {
+		t.Errorf("expected o2 to be redelivered, got %+v", msg)
+	}
+}
+
+func TestTabletHandler_RejectsUnknownMessages(t *testing.T) {
+	_, ts := newTabletTestServer(t)
+	conn := dialTablet(t, ts, "Tony's Pizza")
+
+	if err := wsjson.Write(context.Background(), conn, map[string]string{"type": "hello"}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if msg := readTablet(t, conn); msg.Type != "error" {
+		t.Errorf("expected an error message, got %+v", msg)
+	}
+}
+
+func TestTabletHandler_RequiresRestaurant(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer().tabletHandler(rec, httptest.NewRequest(http.MethodGet, "/ws/tablets", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestTabletHandler_RestaurantClaimLimitsTablets(t *testing.T) {
+	issuer := newTestIssuer(t)
+	ts := httptest.NewServer(newHTTPHandler(newJWTServer(t, issuer)))
+	t.Cleanup(ts.Close)
+	tonys := issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("tablet@tonys", "orders:write", "tonys-pizza"))
+	ops := issuer.sign(t, jwt.SigningMethodRS256, "rsa-1", testClaims("ops", "admin", "tonys-pizza"))
+
+	testCases := []struct {
+		name, token, restaurant string
+		want                    int
+	}{
+		{"own restaurant", tonys, "Tony's Pizza", http.StatusSwitchingProtocols},
+		{"another restaurant", tonys, "Thai Palace", http.StatusForbidden},
+		{"admin", ops, "Thai Palace", http.StatusSwitchingProtocols},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/tablets?restaurant=" + url.QueryEscape(tc.restaurant)
+			conn, resp, err := websocket.Dial(ctx, u, &websocket.DialOptions{HTTPHeader: bearerHeader(tc.token)})
+			if conn != nil {
+				defer func() { _ = conn.CloseNow() }()
+			}
+			if resp == nil {
+				t.Fatalf("expected a response, got %v", err)
+			}
+			if resp.StatusCode != tc.want {
+				t.Errorf("expected status %d, got %d (%v)", tc.want, resp.StatusCode, err)
+			}
+		})
+	}
+}
+
+func TestTabletHandler_ClosesOnDrain(t *testing.T) {
+	server, ts := newTabletTestServer(t)
+	conn := dialTablet(t, ts, "Tony's Pizza")
+	waitForTablets(t, server.tablets, "Tony's Pizza", 1)
+
+	_ = server.drain(ShutdownConfig{Timeout: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, _, err := conn.Read(ctx)
+	if websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("expected going-away close, got %v", err)
+	}
+}
+
+func TestRegisterTabletMetrics(t *testing.T) {
+	hub := newTabletHub()
+	hub.Dispatch(context.Background(), testOrder("o1", "Tony's Pizza", time.Now()))
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := registerTabletMetrics(provider.Meter("test"), hub); err != nil {
+		t.Fatalf("registerTabletMetrics returned error: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "tablet.orders.unacknowledged" {
+			continue
+		}
+		points := m.Data.(metricdata.Gauge[int64]).DataPoints
+		if len(points) != 1 || points[0].Value != 1 {
+			t.Fatalf("expected one restaurant with 1 pending order, got %+v", points)
+		}
+		if v, _ := points[0].Attributes.Value("restaurant"); v.AsString() != "Tony's Pizza" {
+			t.Errorf("expected restaurant attribute, got %v", v)
+		}
+		return
+	}
+	t.Fatal("tablet.orders.unacknowledged was not reported")
+}
+
+func TestRandomOrder_UsesAvailableItems(t *testing.T) {
+	server := NewServer()
+
+	for i := 0; i < 20; i++ {
+		order, ok := server.randomOrder(context.Background())
+		if !ok {
+			t.Fatal("expected an order")
+		}
+		if order.Items[0].MenuItemID == "3" {
+			t.Fatal("expected unavailable items never to be ordered")
+		}
+		if order.Total != order.Items[0].Price*float64(order.Items[0].Quantity) {
+			t.Errorf("unexpected total %v for %+v", order.Total, order.Items[0])
+		}
+	}
+}
+
+func waitForTablets(t *testing.T, h *tabletHub, restaurant string, n int) {
+	t.Helper()
+	eventually(t, func() bool { _, connected := h.counts(); return connected[restaurant] == n })
+}
+
+func waitForUnacked(t *testing.T, h *tabletHub, restaurant string, n int) {
+	t.Helper()
+	eventually(t, func() bool { unacked, _ := h.counts(); return unacked[restaurant] == n })
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
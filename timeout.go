@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errRouteTimeout is the cause of a request context whose route ran out of
+// its time budget, telling it apart from the client going away.
+var errRouteTimeout = errors.New("route time budget exceeded")
+
+// timeoutPolicy is a TimeoutConfig parsed for use on every request.
+type timeoutPolicy struct {
+	fallback time.Duration
+	routes   map[string]time.Duration
+}
+
+func newTimeoutPolicy(cfg TimeoutConfig) (*timeoutPolicy, error) {
+	if cfg.Default < 0 {
+		return nil, fmt.Errorf("default: must not be negative, got %s", cfg.Default)
+	}
+	p := &timeoutPolicy{fallback: cfg.Default, routes: map[string]time.Duration{}}
+	for pattern, spec := range cfg.Routes {
+		if !isRoutePattern(pattern) {
+			return nil, fmt.Errorf("routes: %q is not a route pattern like \"GET /api/menu\"", pattern)
+		}
+		budget, err := time.ParseDuration(spec)
+		if err != nil {
+			return nil, fmt.Errorf("routes: %s: %w", pattern, err)
+		}
+		if budget < 0 {
+			return nil, fmt.Errorf("routes: %s: must not be negative, got %s", pattern, budget)
+		}
+		p.routes[pattern] = budget
+	}
+	return p, nil
+}
+
+// budgetFor returns the time budget of rt, or 0 if it has none.
+func (p *timeoutPolicy) budgetFor(rt route) time.Duration {
+	if budget, ok := p.routes[rt.pattern]; ok {
+		return budget
+	}
+	return p.fallback
+}
+
+// longest returns the largest budget of any route.
+func (p *timeoutPolicy) longest() time.Duration {
+	longest := p.fallback
+	for _, budget := range p.routes {
+		longest = max(longest, budget)
+	}
+	return longest
+}
+
+// routeTimeouts bounds how long each route may take. Handlers see the budget
+// as their request context's deadline, so the menu store and order dispatch
+// give up with context.DeadlineExceeded once it has passed.
+type routeTimeouts struct {
+	policy   atomic.Pointer[timeoutPolicy]
+	exceeded metric.Int64Counter
+}
+
+func newRouteTimeouts(cfg TimeoutConfig) (*routeTimeouts, error) {
+	policy, err := newTimeoutPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exceeded, err := meter.Int64Counter("http.server.timeouts",
+		metric.WithDescription("HTTP requests that ran out of their route's time budget."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("timeout counter: %w", err)
+	}
+
+	t := &routeTimeouts{exceeded: exceeded}
+	t.policy.Store(policy)
+	return t, nil
+}
+
+// configure applies cfg, keeping the current budgets if it is invalid.
+func (t *routeTimeouts) configure(cfg TimeoutConfig) {
+	policy, err := newTimeoutPolicy(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid timeouts, keeping the previous ones")
+		return
+	}
+	t.policy.Store(policy)
+}
+
+// limit runs the handler of rt under its time budget. A handler that notices
+// the deadline answers with writeTimeout; one that returns without answering
+// after the deadline gets the same 504 written for it. Either way the request
+// is counted, traced and logged. Streaming routes are left alone, since they
+// stay open for as long as the client wants.
+func (t *routeTimeouts) limit(rt route, next http.Handler) http.Handler {
+	if rt.streaming() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := t.policy.Load().budgetFor(rt)
+		if budget <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeoutCause(r.Context(), budget, errRouteTimeout)
+		defer cancel()
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		if !errors.Is(context.Cause(ctx), errRouteTimeout) {
+			return
+		}
+		if tw.status != 0 && tw.status != http.StatusGatewayTimeout {
+			// Answered in time; the deadline passed while returning.
+			return
+		}
+
+		t.exceeded.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("http.route", rt.path()),
+			attribute.String("http.request.method", rt.method()),
+		))
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(
+			attribute.Bool("timeout.exceeded", true),
+			attribute.Int64("timeout.budget_ms", budget.Milliseconds()),
+		)
+		span.AddEvent("deadline exceeded", trace.WithAttributes(
+			attribute.Int64("timeout.overrun_ms", (time.Since(start)-budget).Milliseconds()),
+		))
+		addLogField(ctx, "timeout", budget.String())
+		if tw.status == 0 {
+			writeTimeout(tw, r)
+		}
+	})
+}
+
+// writeTimeout answers a request whose context deadline has passed.
+func writeTimeout(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusGatewayTimeout, errCodeTimeout, "Request did not complete within its time budget")
+}
+
+// timeoutWriter records whether and with which status the handler answered.
+type timeoutWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.status == 0 {
+		tw.status = code
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
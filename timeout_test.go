@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// stallingStore never answers Get, giving up only when the caller's context
+// ends, like a database that has stopped responding.
+type stallingStore struct {
+	MenuStore
+}
+
+func (s stallingStore) Get(ctx context.Context, _ string) (MenuItem, error) {
+	<-ctx.Done()
+	return MenuItem{}, ctx.Err()
+}
+
+func TestTimeoutPolicy(t *testing.T) {
+	policy, err := newTimeoutPolicy(TimeoutConfig{
+		Default: time.Second,
+		Routes:  map[string]string{"GET /api/menu/{id}": "250ms", "POST /graphql": "0s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pattern, want := range map[string]time.Duration{
+		"GET /api/menu/{id}": 250 * time.Millisecond,
+		"POST /graphql":      0,
+		"GET /api/menu":      time.Second,
+	} {
+		if got := policy.budgetFor(route{pattern: pattern}); got != want {
+			t.Errorf("%s: expected a budget of %s, got %s", pattern, want, got)
+		}
+	}
+	if got := policy.longest(); got != time.Second {
+		t.Errorf("expected the longest budget to be 1s, got %s", got)
+	}
+
+	for _, cfg := range []TimeoutConfig{
+		{Default: -time.Second},
+		{Routes: map[string]string{"/api/menu": "1s"}},
+		{Routes: map[string]string{"GET /api/menu": "soon"}},
+		{Routes: map[string]string{"GET /api/menu": "-1s"}},
+	} {
+		if _, err := newTimeoutPolicy(cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestConfig_TimeoutsMustFitWriteTimeout(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Timeouts.Routes = map[string]string{"GET /api/menu": cfg.Server.WriteTimeout.String()}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "server.write_timeout") {
+		t.Errorf("expected a budget as long as the write timeout to be rejected, got %v", err)
+	}
+}
+
+func TestTimeouts_Returns504WhenStoreRunsOut(t *testing.T) {
+	recorder := installSpanRecorder()
+	server := newTestServerWithoutFaults()
+	server.menu = stallingStore{MenuStore: server.menu}
+	server.timeouts.configure(TimeoutConfig{
+		Default: time.Minute,
+		Routes:  map[string]string{"GET /api/menu/{id}": "20ms"},
+	})
+	handler := newHTTPHandler(server)
+	seen := len(recorder.Ended())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/menu/1", nil)
+	req.Header.Set("Accept", problemContentType)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the request to end at its budget, took %s", elapsed)
+	}
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != errCodeTimeout {
+		t.Errorf("expected a TIMEOUT problem, got %q", rec.Body.String())
+	}
+
+	var exceeded, event bool
+	for _, span := range recorder.Ended()[seen:] {
+		if span.SpanKind() != trace.SpanKindServer {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "timeout.exceeded" && attr.Value.AsBool() {
+				exceeded = true
+			}
+		}
+		for _, e := range span.Events() {
+			event = event || e.Name == "deadline exceeded"
+		}
+	}
+	if !exceeded || !event {
+		t.Errorf("expected the server span to record the exhausted deadline, got attribute %v and event %v", exceeded, event)
+	}
+
+	// Other routes keep the default budget.
+	if rec := serveConditional(handler, "/api/menu", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected the list to be unaffected, got %d", rec.Code)
+	}
+}
+
+func TestTimeouts_AnswersForHandlersThatIgnoreTheDeadline(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.timeouts.configure(TimeoutConfig{Default: 10 * time.Millisecond})
+	rt := route{pattern: "GET /slow"}
+
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{
+			name: "returns without answering",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(30 * time.Millisecond)
+			},
+			want: http.StatusGatewayTimeout,
+		},
+		{
+			name: "answers before the deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				time.Sleep(30 * time.Millisecond)
+			},
+			want: http.StatusAccepted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveConditional(server.timeouts.limit(rt, tc.handler), "/slow", nil)
+			if rec.Code != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTimeouts_SkipStreamingRoutes(t *testing.T) {
+	server := newTestServerWithoutFaults()
+	server.timeouts.configure(TimeoutConfig{Default: time.Nanosecond})
+
+	for _, rt := range server.routes() {
+		if !rt.streaming() {
+			continue
+		}
+		var deadline bool
+		handler := server.timeouts.limit(rt, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, deadline = r.Context().Deadline()
+		}))
+		serveConditional(handler, rt.path(), nil)
+		if deadline {
+			t.Errorf("%s: expected no deadline on a streaming route", rt.pattern)
+		}
+	}
+}
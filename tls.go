@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// tlsMaterial is the certificate and client CA pool served at a point in time.
+type tlsMaterial struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// tlsReloader serves a certificate (and optionally a client CA bundle) from
+// disk and swaps in new files when they are rotated, without dropping
+// connections or restarting the listener.
+type tlsReloader struct {
+	cfg          TLSConfig
+	clientCAFile string
+	current      atomic.Pointer[tlsMaterial]
+}
+
+// newTLSReloader loads the certificate of cfg and, unless clientCAFile is
+// empty, the CAs that listeners verifying client certificates trust.
+func newTLSReloader(cfg TLSConfig, clientCAFile string) (*tlsReloader, error) {
+	r := &tlsReloader{cfg: cfg, clientCAFile: clientCAFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+func (r *tlsReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS key pair: %w", err)
+	}
+
+	m := &tlsMaterial{cert: &cert}
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA file: %w", err)
+		}
+		m.clientCAs = x509.NewCertPool()
+		if !m.clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file contains no PEM certificates")
+		}
+	}
+
+	r.current.Store(m)
+	return nil
+}
+
+// watch reloads the certificate whenever any of its files change. A failed
+// reload keeps serving the previous certificate.
+func (r *tlsReloader) watch(ctx context.Context, interval time.Duration) {
+	watchFiles(ctx, interval, r.files(), func() {
+		if err := r.reload(); err != nil {
+			log.Error().Err(err).Msg("Failed to reload TLS certificate, keeping previous certificate")
+			return
+		}
+		log.Info().
+			Str("cert_file", r.cfg.CertFile).
+			Time("not_after", r.notAfter()).
+			Msg("TLS certificate loaded")
+	})
+}
+
+func (r *tlsReloader) notAfter() time.Time {
+	return r.current.Load().cert.Leaf.NotAfter
+}
+
+// serverConfig returns a tls.Config that resolves the certificate and client
+// CAs on every handshake, so rotations apply to new connections immediately.
+// nextProtos is advertised via ALPN, and clientAuth sets whether the listener
+// asks for client certificates.
+func (r *tlsReloader) serverConfig(nextProtos []string, clientAuth tls.ClientAuthType) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		ClientAuth: clientAuth,
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		m := r.current.Load()
+		perConn := base.Clone()
+		perConn.Certificates = []tls.Certificate{*m.cert}
+		perConn.ClientCAs = m.clientCAs
+		return perConn, nil
+	}
+	return cfg
+}
+
+// registerCertExpiryMetric publishes when the serving certificate expires so
+// alerts can fire well before it does.
+func registerCertExpiryMetric(meter metric.Meter, r *tlsReloader) error {
+	_, err := meter.Float64ObservableGauge("tls.certificate.expiry",
+		metric.WithDescription("Unix time at which the serving TLS certificate expires."),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			leaf := r.current.Load().cert.Leaf
+			o.Observe(float64(leaf.NotAfter.Unix()), metric.WithAttributes(
+				attribute.String("cert_file", r.cfg.CertFile),
+				attribute.String("subject", leaf.Subject.CommonName),
+			))
+			return nil
+		}),
+	)
+	return err
+}
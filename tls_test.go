@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// testCA issues certificates for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM-encoded certificate and key for a leaf expiring at notAfter.
+func (ca *testCA) issue(t *testing.T, cn string, notAfter time.Time, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// serveTLS starts an HTTPS server using the reloader and returns its address.
+func serveTLS(t *testing.T, r *tlsReloader, clientAuth tls.ClientAuthType) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.serverConfig([]string{"h2", "http/1.1"}, clientAuth))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return ln.Addr().String()
+}
+
+func dialTLS(addr string, ca *testCA, clientCert *tls.Certificate) (*tls.ConnectionState, error) {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	cfg := &tls.Config{RootCAs: pool}
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{*clientCert}
+	}
+
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// With TLS 1.3 a rejected client certificate only surfaces on first read.
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte("GET / HTTP/1.0\r\n\r\n")); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return nil, err
+	}
+	state := conn.ConnectionState()
+	return &state, nil
+}
+
+type tlsFixture struct {
+	ca           *testCA
+	cfg          TLSConfig
+	clientCAFile string
+	notAfter     time.Time
+}
+
+func newTLSFixture(t *testing.T, clientAuth string) *tlsFixture {
+	t.Helper()
+
+	dir := t.TempDir()
+	f := &tlsFixture{
+		ca:       newTestCA(t),
+		notAfter: time.Now().Add(48 * time.Hour).Truncate(time.Second),
+		cfg: TLSConfig{
+			CertFile: filepath.Join(dir, "tls.crt"),
+			KeyFile:  filepath.Join(dir, "tls.key"),
+		},
+	}
+	f.rotate(t, f.notAfter)
+
+	if clientAuth != "none" {
+		f.clientCAFile = filepath.Join(dir, "ca.crt")
+		writeFile(t, f.clientCAFile, f.ca.pem)
+	}
+	return f
+}
+
+func (f *tlsFixture) rotate(t *testing.T, notAfter time.Time) {
+	t.Helper()
+
+	certPEM, keyPEM := f.ca.issue(t, "menu.test", notAfter, x509.ExtKeyUsageServerAuth)
+	writeFile(t, f.cfg.CertFile, certPEM)
+	writeFile(t, f.cfg.KeyFile, keyPEM)
+}
+
+// =============================================================================
+// tlsReloader Tests
+// =============================================================================
+
+func TestTLSReloader_ServesCertificate(t *testing.T) {
+	f := newTLSFixture(t, "none")
+
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+
+	state, err := dialTLS(serveTLS(t, r, tls.NoClientCert), f.ca, nil)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	if got := state.PeerCertificates[0].NotAfter; !got.Equal(f.notAfter) {
+		t.Errorf("expected certificate expiring %v, got %v", f.notAfter, got)
+	}
+}
+
+func TestTLSReloader_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}
+
+	if _, err := newTLSReloader(cfg, ""); err == nil {
+		t.Error("expected error for missing certificate files")
+	}
+}
+
+func TestTLSReloader_RotationWithoutRestart(t *testing.T) {
+	f := newTLSFixture(t, "none")
+
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+	addr := serveTLS(t, r, tls.NoClientCert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx, 10*time.Millisecond)
+
+	rotated := time.Now().Add(96 * time.Hour).Truncate(time.Second)
+	f.rotate(t, rotated)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !r.notAfter().Equal(rotated) {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	state, err := dialTLS(addr, f.ca, nil)
+	if err != nil {
+		t.Fatalf("handshake after rotation failed: %v", err)
+	}
+	if got := state.PeerCertificates[0].NotAfter; !got.Equal(rotated) {
+		t.Errorf("expected rotated certificate expiring %v, got %v", rotated, got)
+	}
+}
+
+func TestTLSReloader_KeepsPreviousOnBadRotation(t *testing.T) {
+	f := newTLSFixture(t, "none")
+
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+
+	writeFile(t, f.cfg.KeyFile, []byte("not a key"))
+
+	if err := r.reload(); err == nil {
+		t.Error("expected reload to fail with a corrupt key")
+	}
+	if !r.notAfter().Equal(f.notAfter) {
+		t.Errorf("expected previous certificate to stay, got expiry %v", r.notAfter())
+	}
+}
+
+func TestTLSReloader_RequireClientCertificate(t *testing.T) {
+	f := newTLSFixture(t, "require")
+
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+	addr := serveTLS(t, r, tls.RequireAndVerifyClientCert)
+
+	t.Run("Without certificate", func(t *testing.T) {
+		if _, err := dialTLS(addr, f.ca, nil); err == nil {
+			t.Error("expected handshake without client certificate to fail")
+		}
+	})
+
+	t.Run("Public listener", func(t *testing.T) {
+		if _, err := dialTLS(serveTLS(t, r, tls.NoClientCert), f.ca, nil); err != nil {
+			t.Errorf("expected a listener sharing the certificate not to ask for one, got %v", err)
+		}
+	})
+
+	t.Run("With certificate", func(t *testing.T) {
+		certPEM, keyPEM := f.ca.issue(t, "tablet", time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth)
+		clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatalf("failed to load client certificate: %v", err)
+		}
+
+		if _, err := dialTLS(addr, f.ca, &clientCert); err != nil {
+			t.Errorf("expected handshake with client certificate to succeed, got %v", err)
+		}
+	})
+
+	t.Run("Untrusted certificate", func(t *testing.T) {
+		other := newTestCA(t)
+		certPEM, keyPEM := other.issue(t, "intruder", time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth)
+		clientCert, _ := tls.X509KeyPair(certPEM, keyPEM)
+
+		if _, err := dialTLS(addr, f.ca, &clientCert); err == nil {
+			t.Error("expected handshake with untrusted client certificate to fail")
+		}
+	})
+}
+
+func TestRegisterCertExpiryMetric(t *testing.T) {
+	f := newTLSFixture(t, "none")
+	r, err := newTLSReloader(f.cfg, f.clientCAFile)
+	if err != nil {
+		t.Fatalf("newTLSReloader returned error: %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	if err := registerCertExpiryMetric(provider.Meter("test"), r); err != nil {
+		t.Fatalf("registerCertExpiryMetric returned error: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	gauge, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("expected a single float64 gauge data point, got %+v", rm.ScopeMetrics[0].Metrics[0].Data)
+	}
+	if got := int64(gauge.DataPoints[0].Value); got != f.notAfter.Unix() {
+		t.Errorf("expected expiry %d, got %d", f.notAfter.Unix(), got)
+	}
+}
+
+// =============================================================================
+// TLSConfig Validation Tests
+// =============================================================================
+
+func TestTLSConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mutate   func(*Config)
+		contains string
+	}{
+		{"cert without key", func(c *Config) { c.Server.TLS = TLSConfig{CertFile: "tls.crt"} }, "server.tls.key_file"},
+		{"unknown policy", func(c *Config) { c.Admin.ClientAuth = "maybe" }, "admin.client_auth"},
+		{"require without CA", func(c *Config) {
+			c.Server.TLS = TLSConfig{CertFile: "tls.crt", KeyFile: "tls.key"}
+			c.Admin = AdminConfig{Addr: ":8081", ClientAuth: "require"}
+		}, "admin.client_ca_file"},
+		{"CA without TLS", func(c *Config) { c.Admin = AdminConfig{Addr: ":8081", ClientCAFile: "ca.crt", ClientAuth: "none"} }, "requires server.tls.cert_file"},
+		{"admin on the public port", func(c *Config) { c.Admin.Addr = c.Server.Addr }, "admin.addr"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tc.mutate(&cfg)
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("expected error containing %q, got %v", tc.contains, err)
+			}
+		})
+	}
+}
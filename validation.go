@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRequestBody caps the bodies read for validation.
+const maxRequestBody = 1 << 20
+
+// Violation is one way a request fails to match the API schema.
+type Violation struct {
+	// Location is "path", "query", "header" or "body".
+	Location string `json:"location"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+}
+
+// validateRequests checks requests against the operation documented for rt
+// in spec before they reach the handler, answering mismatches with a 400 that
+// lists every violation.
+func validateRequests(spec *openAPIDocument, rt route, next http.Handler) http.Handler {
+	op := spec.Paths[rt.path()][strings.ToLower(rt.method())]
+	if op == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		violations, err := spec.validateRequest(op, r)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeError(w, r, http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+			return
+		case err != nil:
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidRequest, "Failed to read request body")
+			return
+		case len(violations) > 0:
+			writeError(w, r, http.StatusBadRequest, errCodeValidationFailed, "Request does not match the API schema", violations...)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateRequest returns the violations of r against op. A body it reads is
+// put back on r for the handler.
+func (d *openAPIDocument) validateRequest(op *openAPIOperation, r *http.Request) ([]Violation, error) {
+	var violations []Violation
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value = r.PathValue(p.Name)
+			present = value != ""
+		case "query":
+			present = query.Has(p.Name)
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		}
+		if !present {
+			if p.Required {
+				violations = append(violations, Violation{p.In, p.Name, "is required"})
+			}
+			continue
+		}
+		if msg := d.checkParam(p.Schema, value); msg != "" {
+			violations = append(violations, Violation{p.In, p.Name, msg})
+		}
+	}
+
+	if op.RequestBody == nil {
+		return violations, nil
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			return append(violations, Violation{"header", "Content-Type", "must be application/json"}), nil
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			violations = append(violations, Violation{"body", "", "is required"})
+		}
+		return violations, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return append(violations, Violation{"body", "", "must be valid JSON"}), nil
+	}
+	schema := op.RequestBody.Content["application/json"].Schema
+	return append(violations, d.checkValue(schema, value, "")...), nil
+}
+
+func (d *openAPIDocument) resolve(s *jsonSchema) *jsonSchema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// checkParam validates a raw parameter value, returning a message describing
+// the problem or "".
+func (d *openAPIDocument) checkParam(s *jsonSchema, raw string) string {
+	s = d.resolve(s)
+	if s == nil {
+		return ""
+	}
+	switch s.Type {
+	case "boolean":
+		// A bare flag such as ?verbose counts as true.
+		if _, err := strconv.ParseBool(raw); raw != "" && err != nil {
+			return "must be a boolean"
+		}
+		return ""
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		return checkMinimum(s, float64(n))
+	case "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return "must be a number"
+		}
+		return checkMinimum(s, n)
+	default:
+		return checkString(s, raw)
+	}
+}
+
+// checkValue validates a decoded JSON value. field is the dotted path to the
+// value, empty for the document root.
+func (d *openAPIDocument) checkValue(s *jsonSchema, value any, field string) []Violation {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+	fail := func(msg string) []Violation { return []Violation{{"body", field, msg}} }
+
+	switch s.Type {
+	case "object":
+		if value == nil {
+			return nil
+		}
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		var violations []Violation
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				violations = append(violations, Violation{"body", joinField(field, name), "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				// Only maps allow properties beyond the declared ones, so
+				// a misspelt field is reported rather than ignored.
+				if s.AdditionalProperties == nil {
+					violations = append(violations, Violation{"body", joinField(field, name), "is not allowed"})
+					continue
+				}
+				prop = s.AdditionalProperties
+			}
+			violations = append(violations, d.checkValue(prop, obj[name], joinField(field, name))...)
+		}
+		return violations
+	case "array":
+		if value == nil {
+			return nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		var violations []Violation
+		for i, item := range items {
+			violations = append(violations, d.checkValue(s.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+		return violations
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if msg := checkString(s, str); msg != "" {
+			return fail(msg)
+		}
+	case "integer":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fail("must be an integer")
+		}
+		n, err := num.Int64()
+		if err != nil {
+			return fail("must be an integer")
+		}
+		if msg := checkMinimum(s, float64(n)); msg != "" {
+			return fail(msg)
+		}
+	case "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fail("must be a number")
+		}
+		n, _ := num.Float64()
+		if msg := checkMinimum(s, n); msg != "" {
+			return fail(msg)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+	return nil
+}
+
+func checkString(s *jsonSchema, value string) string {
+	if s.MinLength != nil && utf8.RuneCountInString(value) < *s.MinLength {
+		return fmt.Sprintf("must be at least %d characters", *s.MinLength)
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		return "must be one of " + strings.Join(s.Enum, ", ")
+	}
+	return ""
+}
+
+func checkMinimum(s *jsonSchema, n float64) string {
+	if s.Minimum != nil && n < *s.Minimum {
+		return fmt.Sprintf("must be at least %s", strconv.FormatFloat(*s.Minimum, 'f', -1, 64))
+	}
+	return ""
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func serveValidated(t *testing.T, req *http.Request) (*httptest.ResponseRecorder, ErrorResponse) {
+	t.Helper()
+
+	// Every scope is granted anonymously so requests reach validation;
+	// routes needing more than menu:read are refused while auth is off.
+	server := newAuthenticatedServer(t, AuthConfig{
+		APIKeys:         map[string]string{"ci": hashedAPIKey("ci-secret", "admin")},
+		AnonymousScopes: []string{"menu:read", "menu:write", "orders:write"},
+	})
+	rec := httptest.NewRecorder()
+	newHTTPHandler(server).ServeHTTP(rec, req)
+
+	var body ErrorResponse
+	if rec.Code == http.StatusBadRequest {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode error body: %v", err)
+		}
+	}
+	return rec, body
+}
+
+func TestValidation_RejectsBadParams(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		want []Violation
+	}{
+		{
+			name: "non-integer query param",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/api/menu/stream?last_event_id=abc", nil)
+			},
+			want: []Violation{{"query", "last_event_id", "must be an integer"}},
+		},
+		{
+			name: "negative header param",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/api/menu/stream", nil)
+				req.Header.Set("Last-Event-ID", "-1")
+				return req
+			},
+			want: []Violation{{"header", "Last-Event-ID", "must be at least 0"}},
+		},
+		{
+			name: "missing required query param",
+			req:  func() *http.Request { return httptest.NewRequest(http.MethodGet, "/ws/tablets", nil) },
+			want: []Violation{{"query", "restaurant", "is required"}},
+		},
+		{
+			name: "invalid boolean",
+			req:  func() *http.Request { return httptest.NewRequest(http.MethodGet, "/readyz?verbose=maybe", nil) },
+			want: []Violation{{"query", "verbose", "must be a boolean"}},
+		},
+		{
+			name: "missing query with other params",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/graphql?operationName=x", nil)
+			},
+			want: []Violation{{"query", "query", "is required"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body := serveValidated(t, tt.req())
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			if body.Error != "Bad Request" {
+				t.Errorf("expected error 'Bad Request', got %q", body.Error)
+			}
+			if !reflect.DeepEqual(body.Violations, tt.want) {
+				t.Errorf("expected violations %+v, got %+v", tt.want, body.Violations)
+			}
+		})
+	}
+}
+
+func TestValidation_RejectsBadBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []Violation
+	}{
+		{"missing field", "application/json", `{"variables": {}}`, []Violation{{"body", "query", "is required"}}},
+		{"wrong type", "application/json", `{"query": 42, "variables": []}`, []Violation{
+			{"body", "query", "must be a string"},
+			{"body", "variables", "must be an object"},
+		}},
+		{"unknown field", "application/json", `{"query": "{ categories { name } }", "querry": "x"}`, []Violation{{"body", "querry", "is not allowed"}}},
+		{"not json", "application/json", `{"query":`, []Violation{{"body", "", "must be valid JSON"}}},
+		{"empty", "application/json", ``, []Violation{{"body", "", "is required"}}},
+		{"wrong content type", "text/plain", `{"query": "{ categories { name } }"}`, []Violation{{"header", "Content-Type", "must be application/json"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec, body := serveValidated(t, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			if !reflect.DeepEqual(body.Violations, tt.want) {
+				t.Errorf("expected violations %+v, got %+v", tt.want, body.Violations)
+			}
+		})
+	}
+}
+
+func TestValidation_PassesValidRequests(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ categories { name } }", "variables": {"any": 1}}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec, _ := serveValidated(t, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"categories"`) {
+		t.Errorf("handler did not see the request body: %s", rec.Body.String())
+	}
+
+	for _, url := range []string{"/api/menu/1", "/readyz?verbose", "/livez?verbose=false"} {
+		rec, _ := serveValidated(t, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code == http.StatusBadRequest {
+			t.Errorf("%s: unexpected 400: %s", url, rec.Body.String())
+		}
+	}
+}
+
+func TestValidation_RejectsUnknownMenuItemFields(t *testing.T) {
+	body := `{"id": "1", "name": "Margherita Pizza", "price": 12.99, "prise": 3, "available": true, "description": "", "restaurant": "Tony's Pizza", "category": "Pizza", "prep_time_minutes": 20}`
+	req := httptest.NewRequest(http.MethodPut, "/api/menu/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec, problem := serveValidated(t, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if want := []Violation{{"body", "prise", "is not allowed"}}; !reflect.DeepEqual(problem.Violations, want) {
+		t.Errorf("expected violations %+v, got %+v", want, problem.Violations)
+	}
+}
+
+func TestValidation_RejectsLargeBody(t *testing.T) {
+	body := `{"query": "` + strings.Repeat("a", maxRequestBody) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	rec, _ := serveValidated(t, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// apiVersion is a version of the REST response shapes. v1 is what /api/menu
+// has always returned; v2 adds restaurant IDs and money types.
+type apiVersion string
+
+const (
+	apiV1 apiVersion = "v1"
+	apiV2 apiVersion = "v2"
+)
+
+// Media types that select a version on the unversioned /api/menu routes.
+const (
+	mediaTypeMenuV1 = "application/vnd.menu.v1+json"
+	mediaTypeMenuV2 = "application/vnd.menu.v2+json"
+)
+
+// menuCurrency is the ISO 4217 currency of every menu price.
+const menuCurrency = "USD"
+
+var (
+	// apiV1Deprecated is when v2 shipped and v1 became deprecated.
+	apiV1Deprecated = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	// apiV1Sunset is when v1 may stop being served.
+	apiV1Sunset = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+type apiVersionKey struct{}
+
+func apiVersionFrom(ctx context.Context) apiVersion {
+	if v, ok := ctx.Value(apiVersionKey{}).(apiVersion); ok {
+		return v
+	}
+	return apiV1
+}
+
+// negotiateAPIVersion picks the version for a request to an unversioned route
+// from its Accept header, defaulting to v1. The menu media type with the
+// highest q wins, the first listed on a tie; ranges with q=0 are refused
+// rather than acceptable, so they select nothing.
+func negotiateAPIVersion(r *http.Request) (apiVersion, string) {
+	best, bestQ := apiV1, 0.0
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			var v apiVersion
+			switch mediaType {
+			case mediaTypeMenuV2:
+				v = apiV2
+			case mediaTypeMenuV1:
+				v = apiV1
+			default:
+				continue
+			}
+			if q > bestQ {
+				best, bestQ = v, q
+			}
+		}
+	}
+	if bestQ == 0 {
+		return apiV1, "default"
+	}
+	return best, "header"
+}
+
+// versioned serves next as the given API version, or as the version
+// negotiated from Accept when version is empty. Each request is counted per
+// version. Responses to clients that asked for v1, by path or Accept, carry
+// Deprecation and Sunset headers pointing them at v2; clients that got v1 by
+// default never chose it and are not told it is going away.
+func (s *Server) versioned(version apiVersion, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, source := version, "path"
+		if v == "" {
+			v, source = negotiateAPIVersion(r)
+			addVary(w.Header(), "Accept")
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("api.version", string(v)))
+		s.apiRequests.Add(r.Context(), 1, metric.WithAttributes(
+			attribute.String("api.version", string(v)),
+			attribute.String("api.version.source", source),
+		))
+
+		if v == apiV1 && source != "default" {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(apiV1Deprecated.Unix(), 10))
+			w.Header().Set("Sunset", apiV1Sunset.Format(http.TimeFormat))
+			successor := "/api/v2/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/api/")
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		w.Header().Set("API-Version", string(v))
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, v)))
+	}
+}
+
+// Money is an amount in a currency. Amount is a decimal string so clients do
+// not round through floating point.
+type Money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func newMoney(amount float64) Money {
+	return Money{Amount: strconv.FormatFloat(amount, 'f', 2, 64), Currency: menuCurrency}
+}
+
+// RestaurantRef identifies the restaurant serving a v2 menu item.
+type RestaurantRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// MenuItemV2 is the v2 shape of a menu item.
+type MenuItemV2 struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Price       Money         `json:"price"`
+	Available   bool          `json:"available"`
+	Description string        `json:"description"`
+	Restaurant  RestaurantRef `json:"restaurant"`
+	Category    string        `json:"category"`
+	PrepTime    int           `json:"prep_time_minutes"`
+}
+
+// MenuListResponseV2 is the v2 body of GET /api/v2/menu.
+type MenuListResponseV2 struct {
+	MenuItems []MenuItemV2 `json:"menu_items"`
+	Count     int          `json:"count"`
+}
+
+// MenuItemResponseV2 is the v2 body of GET /api/v2/menu/{id}.
+type MenuItemResponseV2 struct {
+	MenuItem MenuItemV2 `json:"menu_item"`
+}
+
+func menuItemV2(item MenuItem) MenuItemV2 {
+	return MenuItemV2{
+		ID:          item.ID,
+		Name:        item.Name,
+		Price:       newMoney(item.Price),
+		Available:   item.Available,
+		Description: item.Description,
+		Restaurant:  RestaurantRef{ID: restaurantID(item.Restaurant), Name: item.Restaurant},
+		Category:    item.Category,
+		PrepTime:    item.PrepTime,
+	}
+}
+
+// restaurantID derives a stable slug from a restaurant name, e.g.
+// "Tony's Pizza" becomes "tonys-pizza".
+func restaurantID(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		case r == '\'':
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// menuListBody renders items in the request's API version.
+func menuListBody(ctx context.Context, items []MenuItem) any {
+	if apiVersionFrom(ctx) == apiV2 {
+		out := make([]MenuItemV2, len(items))
+		for i, item := range items {
+			out[i] = menuItemV2(item)
+		}
+		return MenuListResponseV2{MenuItems: out, Count: len(out)}
+	}
+	return MenuListResponse{MenuItems: items, Count: len(items)}
+}
+
+// menuItemBody renders item in the request's API version.
+func menuItemBody(ctx context.Context, item MenuItem) any {
+	if apiVersionFrom(ctx) == apiV2 {
+		return MenuItemResponseV2{MenuItem: menuItemV2(item)}
+	}
+	return MenuItemResponse{MenuItem: item}
+}
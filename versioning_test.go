@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func getVersioned(t *testing.T, handler http.Handler, url, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: expected status 200, got %d: %s", url, rec.Code, rec.Body.String())
+	}
+	return rec
+}
+
+func TestVersioning_V2Shape(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	for _, tc := range []struct{ url, accept string }{
+		{"/api/v2/menu/1", ""},
+		{"/api/menu/1", mediaTypeMenuV2},
+		{"/api/menu/1", mediaTypeMenuV1 + ";q=0.1, " + mediaTypeMenuV2},
+	} {
+		rec := getVersioned(t, handler, tc.url, tc.accept)
+		var resp MenuItemResponseV2
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode: %v", tc.url, err)
+		}
+		want := MenuItemV2{
+			ID:          "1",
+			Name:        "Margherita Pizza",
+			Price:       Money{Amount: "12.99", Currency: "USD"},
+			Available:   true,
+			Description: "Fresh mozzarella, tomato sauce, basil",
+			Restaurant:  RestaurantRef{ID: "tonys-pizza", Name: "Tony's Pizza"},
+			Category:    "Pizza",
+			PrepTime:    20,
+		}
+		if resp.MenuItem != want {
+			t.Errorf("%s: expected %+v, got %+v", tc.url, want, resp.MenuItem)
+		}
+		if rec.Header().Get("Deprecation") != "" || rec.Header().Get("Sunset") != "" {
+			t.Errorf("%s: v2 response should not be deprecated", tc.url)
+		}
+		if v := rec.Header().Get("API-Version"); v != "v2" {
+			t.Errorf("%s: expected API-Version v2, got %q", tc.url, v)
+		}
+	}
+
+	rec := getVersioned(t, handler, "/api/v2/menu", "")
+	var list MenuListResponseV2
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if list.Count != 5 || len(list.MenuItems) != 5 || list.MenuItems[2].Price.Amount != "11.99" {
+		t.Errorf("unexpected v2 list: %+v", list)
+	}
+}
+
+func TestVersioning_V1KeepsShapeAndIsDeprecated(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	for _, tc := range []struct{ url, accept, successor string }{
+		{"/api/menu/1", "", ""},
+		{"/api/menu/1", mediaTypeMenuV1 + ";q=0", ""},
+		{"/api/v1/menu/1", "", "</api/v2/menu/1>"},
+		{"/api/menu/1", mediaTypeMenuV1, "</api/v2/menu/1>"},
+		{"/api/menu/1", mediaTypeMenuV2 + ";q=0, " + mediaTypeMenuV1, "</api/v2/menu/1>"},
+		{"/api/v1/menu", mediaTypeMenuV2, "</api/v2/menu>"},
+	} {
+		rec := getVersioned(t, handler, tc.url, tc.accept)
+
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: failed to decode: %v", tc.url, err)
+		}
+		if item, ok := body["menu_item"].(map[string]any); ok {
+			if price, ok := item["price"].(float64); !ok || price != 12.99 {
+				t.Errorf("%s: expected v1 numeric price, got %v", tc.url, item["price"])
+			}
+			if restaurant := item["restaurant"]; restaurant != "Tony's Pizza" {
+				t.Errorf("%s: expected v1 restaurant name, got %v", tc.url, restaurant)
+			}
+		}
+
+		if tc.successor == "" {
+			for _, header := range []string{"Deprecation", "Sunset", "Link"} {
+				if got := rec.Header().Get(header); got != "" {
+					t.Errorf("%s with Accept %q: expected no %s for a default v1, got %q", tc.url, tc.accept, header, got)
+				}
+			}
+			continue
+		}
+		if got := rec.Header().Get("Deprecation"); got != "@1792281600" {
+			t.Errorf("%s: expected Deprecation @1792281600, got %q", tc.url, got)
+		}
+		if got := rec.Header().Get("Sunset"); got != "Fri, 30 Apr 2027 00:00:00 GMT" {
+			t.Errorf("%s: unexpected Sunset %q", tc.url, got)
+		}
+		if got, want := rec.Header().Get("Link"), tc.successor+`; rel="successor-version"`; got != want {
+			t.Errorf("%s: expected Link %q, got %q", tc.url, want, got)
+		}
+	}
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		accept     []string
+		want       apiVersion
+		wantSource string
+	}{
+		{nil, apiV1, "default"},
+		{[]string{"application/json"}, apiV1, "default"},
+		{[]string{mediaTypeMenuV2}, apiV2, "header"},
+		{[]string{mediaTypeMenuV1 + ";q=0.1, " + mediaTypeMenuV2}, apiV2, "header"},
+		{[]string{mediaTypeMenuV2 + ";q=0.5, " + mediaTypeMenuV1 + ";q=0.9"}, apiV1, "header"},
+		{[]string{mediaTypeMenuV1 + ";q=0.5, " + mediaTypeMenuV2 + ";q=0.5"}, apiV1, "header"},
+		{[]string{mediaTypeMenuV1 + ";q=0.5", mediaTypeMenuV2 + ";q=0.8"}, apiV2, "header"},
+		{[]string{mediaTypeMenuV2 + ";q=bad, " + mediaTypeMenuV1 + ";q=0.2"}, apiV1, "header"},
+		{[]string{mediaTypeMenuV2 + ";q=0"}, apiV1, "default"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/menu", nil)
+		for _, accept := range tc.accept {
+			req.Header.Add("Accept", accept)
+		}
+		if v, source := negotiateAPIVersion(req); v != tc.want || source != tc.wantSource {
+			t.Errorf("Accept %q: expected %s from %s, got %s from %s", tc.accept, tc.want, tc.wantSource, v, source)
+		}
+	}
+}
+
+func TestVersioning_VaryOnlyForNegotiatedRoutes(t *testing.T) {
+	handler := newHTTPHandler(newTestServerWithoutFaults())
+
+	// Compression adds Accept-Encoding to both; only Accept is ours.
+	if vary := getVersioned(t, handler, "/api/menu", "").Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+		t.Errorf("expected Vary: Accept on /api/menu, got %q", vary)
+	}
+	if vary := getVersioned(t, handler, "/api/v2/menu", "").Header().Values("Vary"); slices.Contains(vary, "Accept") {
+		t.Errorf("expected no Vary: Accept on /api/v2/menu, got %q", vary)
+	}
+}
+
+func TestVersioning_CountsRequestsPerVersion(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	server := newTestServerWithoutFaults()
+	counter, err := provider.Meter("test").Int64Counter("api.requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.apiRequests = counter
+	handler := newHTTPHandler(server)
+
+	getVersioned(t, handler, "/api/menu", "")
+	getVersioned(t, handler, "/api/menu", mediaTypeMenuV2)
+	getVersioned(t, handler, "/api/v2/menu/1", "")
+	getVersioned(t, handler, "/api/v2/menu", "")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[[2]string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || m.Name != "api.requests" {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				version, _ := dp.Attributes.Value(attribute.Key("api.version"))
+				source, _ := dp.Attributes.Value(attribute.Key("api.version.source"))
+				got[[2]string{version.AsString(), source.AsString()}] = dp.Value
+			}
+		}
+	}
+
+	want := map[[2]string]int64{
+		{"v1", "default"}: 1,
+		{"v2", "header"}:  1,
+		{"v2", "path"}:    2,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("api.requests%v = %d, want %d (all: %v)", k, got[k], v, got)
+		}
+	}
+}
+
+func TestRestaurantID(t *testing.T) {
+	tests := map[string]string{
+		"Tony's Pizza":     "tonys-pizza",
+		"Thai Palace":      "thai-palace",
+		"  Burger  Joint ": "burger-joint",
+		"Café 21":          "café-21",
+	}
+	for name, want := range tests {
+		if got := restaurantID(name); got != want {
+			t.Errorf("restaurantID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}